@@ -2,7 +2,9 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
+	"sort"
 	"strings"
 )
 
@@ -74,9 +76,10 @@ func (bs *BlockStatement) String() string {
 
 // LET
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Expression // ArrayPattern or HashPattern for destructuring, Name is nil then
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -85,7 +88,11 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral())
 	out.WriteString(" ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -114,6 +121,116 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// FOR
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier // first of two loop variables, nil if there is only one
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// BREAK
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+// CONTINUE
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
+// YIELD
+type YieldStatement struct {
+	Token token.Token
+	Value Expression // nil yields null
+}
+
+func (ys *YieldStatement) statementNode()       {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+func (ys *YieldStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ys.TokenLiteral())
+	if ys.Value != nil {
+		out.WriteString(" ")
+		out.WriteString(ys.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// STRUCT
+type StructStatement struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.String())
+	}
+
+	var out bytes.Buffer
+	out.WriteString(ss.TokenLiteral())
+	out.WriteString(" ")
+	out.WriteString(ss.Name.String())
+	if len(fields) == 0 {
+		out.WriteString(" {}")
+	} else {
+		out.WriteString(" { ")
+		out.WriteString(strings.Join(fields, ", "))
+		out.WriteString(" }")
+	}
+	return out.String()
+}
+
+// Assignment to a field like "p.x = 1"
+type AssignStatement struct {
+	Token  token.Token // '='
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(as.Target.String())
+	out.WriteString(" = ")
+	out.WriteString(as.Value.String())
+	out.WriteString(";")
+	return out.String()
+}
+
 // IDENTIFIER
 type Identifier struct {
 	Token token.Token
@@ -134,6 +251,16 @@ func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
 
+// Integer literal out of the range of int64
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bi *BigIntegerLiteral) expressionNode()      {}
+func (bi *BigIntegerLiteral) TokenLiteral() string { return bi.Token.Literal }
+func (bi *BigIntegerLiteral) String() string       { return bi.Token.Literal }
+
 // String literal
 type StringLiteral struct {
 	Token token.Token
@@ -221,8 +348,12 @@ func (ie *IfExpression) String() string {
 // Function Literal
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string // name bound by let statement, empty if anonymous
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values of parameters by name
+	Rest       *Identifier           // parameter collecting remaining arguments, nil if absent
 	Body       *BlockStatement
+	Generator  bool // true if Body contains yield statement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -230,25 +361,45 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
+	if fl.Token.Type == token.ARROW {
+		out.WriteString("(")
+		out.WriteString(strings.Join(ParameterStrings(fl.Parameters, fl.Defaults, fl.Rest), ", "))
+		out.WriteString(") => ")
+		out.WriteString(fl.Body.String())
+		return out.String()
+	}
+
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	for i, p := range fl.Parameters {
-		out.WriteString(p.String())
-		if i != len(fl.Parameters)-1 {
-			out.WriteString(", ")
-		}
-	}
+	out.WriteString(strings.Join(ParameterStrings(fl.Parameters, fl.Defaults, fl.Rest), ", "))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// render parameters of function with their default values and rest parameter
+func ParameterStrings(parameters []*Identifier, defaults map[string]Expression, rest *Identifier) []string {
+	params := []string{}
+	for _, p := range parameters {
+		if value, ok := defaults[p.Value]; ok {
+			params = append(params, p.String()+" = "+value.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+	return params
+}
+
 // CallExpression
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Optional  bool // "f?.()" yields null instead of calling null
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -257,6 +408,9 @@ func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	for i, p := range ce.Arguments {
 		out.WriteString(p.String())
@@ -294,9 +448,10 @@ func (al *ArrayLiteral) String() string {
 
 // IndexExpression
 type IndexExpression struct {
-	Token token.Token // '['
-	Left  Expression
-	Index Expression
+	Token    token.Token // '['
+	Left     Expression
+	Index    Expression
+	Optional bool // "h?.[k]" yields null instead of indexing null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -306,6 +461,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -313,6 +471,32 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// Member access like "p.x"
+type MemberExpression struct {
+	Token    token.Token // '.' or '?.'
+	Object   Expression
+	Member   *Identifier
+	Optional bool // "p?.x" yields null instead of accessing null
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	if me.Optional {
+		out.WriteString("?.")
+	} else {
+		out.WriteString(".")
+	}
+	out.WriteString(me.Member.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // HashLiteral
 type HashLiteral struct {
 	Token token.Token // '{'
@@ -321,6 +505,26 @@ type HashLiteral struct {
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+
+// keys in source order, since the order of Pairs is random
+func (hl *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := NodeToken(keys[i]), NodeToken(keys[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -335,3 +539,246 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// SliceExpression
+type SliceExpression struct {
+	Token    token.Token // '['
+	Left     Expression
+	Start    Expression // nil if omitted
+	End      Expression // nil if omitted
+	Optional bool       // "s?.[a:b]" yields null instead of slicing null
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// InterpolatedString
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression // StringLiteral for literal text, any expression for "${...}"
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string       { return is.Token.Literal }
+
+// SpreadExpression
+type SpreadExpression struct {
+	Token token.Token // '...'
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// PatternElement is a binding target of destructuring with its default value
+type PatternElement struct {
+	Target  Expression // Identifier, ArrayPattern or HashPattern
+	Default Expression // nil if absent
+}
+
+func (pe *PatternElement) String() string {
+	if pe.Default == nil {
+		return pe.Target.String()
+	}
+	return pe.Target.String() + " = " + pe.Default.String()
+}
+
+// ArrayPattern like [a, b = 1, ...rest]
+type ArrayPattern struct {
+	Token    token.Token // '['
+	Elements []*PatternElement
+	Rest     *Identifier // nil if absent
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashPatternPair binds the value of Key to Value
+type HashPatternPair struct {
+	Key   *StringLiteral
+	Value *PatternElement
+}
+
+func (hp *HashPatternPair) String() string {
+	if ident, ok := hp.Value.Target.(*Identifier); ok && ident.Value == hp.Key.Value {
+		return hp.Value.String()
+	}
+	return hp.Key.String() + ": " + hp.Value.String()
+}
+
+// HashPattern like {name, age: years = 0, ...others}
+type HashPattern struct {
+	Token token.Token // '{'
+	Pairs []*HashPatternPair
+	Rest  *Identifier // nil if absent
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		pairs = append(pairs, pair.String())
+	}
+	if hp.Rest != nil {
+		pairs = append(pairs, "..."+hp.Rest.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// PipeExpression like "arr |> map(f)", calling Right with Left as the first argument
+type PipeExpression struct {
+	Token token.Token // '|>'
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode()      {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(" |> ")
+	out.WriteString(pe.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// ConditionalExpression like "cond ? a : b"
+type ConditionalExpression struct {
+	Token       token.Token // '?'
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ce.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(ce.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(ce.Alternative.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// token a node was parsed from, the zero token for nodes without one
+func NodeToken(node Node) token.Token {
+	switch node := node.(type) {
+	case *ExpressionStatement:
+		return node.Token
+	case *BlockStatement:
+		return node.Token
+	case *LetStatement:
+		return node.Token
+	case *ReturnStatement:
+		return node.Token
+	case *ForStatement:
+		return node.Token
+	case *BreakStatement:
+		return node.Token
+	case *ContinueStatement:
+		return node.Token
+	case *YieldStatement:
+		return node.Token
+	case *StructStatement:
+		return node.Token
+	case *AssignStatement:
+		return node.Token
+	case *Identifier:
+		return node.Token
+	case *IntegerLiteral:
+		return node.Token
+	case *BigIntegerLiteral:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *Boolean:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *InfixExpression:
+		return node.Token
+	case *IfExpression:
+		return node.Token
+	case *FunctionLiteral:
+		return node.Token
+	case *CallExpression:
+		return node.Token
+	case *ArrayLiteral:
+		return node.Token
+	case *IndexExpression:
+		return node.Token
+	case *MemberExpression:
+		return node.Token
+	case *HashLiteral:
+		return node.Token
+	case *SliceExpression:
+		return node.Token
+	case *InterpolatedString:
+		return node.Token
+	case *SpreadExpression:
+		return node.Token
+	case *ArrayPattern:
+		return node.Token
+	case *HashPattern:
+		return node.Token
+	case *PipeExpression:
+		return node.Token
+	case *ConditionalExpression:
+		return node.Token
+	default:
+		return token.Token{}
+	}
+}
@@ -1,7 +1,10 @@
 package ast
 
 import (
+	"fmt"
+	"math/big"
 	"monkey/token"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -26,3 +29,257 @@ func TestProgramString(t *testing.T) {
 
 	require.Equal(t, "let myVar = anotherVar;", program.String(), "Wrong program.String()")
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{&SliceExpression{Left: one(), End: one()}, &SliceExpression{Left: two(), End: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Name: ident("x"), Value: one()}, &LetStatement{Name: ident("x"), Value: two()}},
+		{&YieldStatement{Value: one()}, &YieldStatement{Value: two()}},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{ident("x")},
+				Defaults:   map[string]Expression{"x": one()},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{ident("x")},
+				Defaults:   map[string]Expression{"x": two()},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&CallExpression{Function: ident("f"), Arguments: []Expression{one(), &SpreadExpression{Value: one()}}},
+			&CallExpression{Function: ident("f"), Arguments: []Expression{two(), &SpreadExpression{Value: two()}}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{&MemberExpression{Object: one(), Member: ident("x")}, &MemberExpression{Object: two(), Member: ident("x")}},
+		{
+			&AssignStatement{Target: &MemberExpression{Object: one(), Member: ident("x")}, Value: one()},
+			&AssignStatement{Target: &MemberExpression{Object: two(), Member: ident("x")}, Value: two()},
+		},
+		{&InterpolatedString{Parts: []Expression{&StringLiteral{Value: "a"}, one()}}, &InterpolatedString{Parts: []Expression{&StringLiteral{Value: "a"}, two()}}},
+		{&PipeExpression{Left: one(), Right: ident("f")}, &PipeExpression{Left: two(), Right: ident("f")}},
+		{&ConditionalExpression{Condition: one(), Consequence: one(), Alternative: one()}, &ConditionalExpression{Condition: two(), Consequence: two(), Alternative: two()}},
+		{
+			&ForStatement{Value: ident("x"), Iterable: one(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&ForStatement{Value: ident("x"), Iterable: two(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []*PatternElement{{Target: ident("a"), Default: one()}}},
+				Value:   one(),
+			},
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []*PatternElement{{Target: ident("a"), Default: two()}}},
+				Value:   two(),
+			},
+		},
+		{
+			&HashPattern{Pairs: []*HashPatternPair{{Key: &StringLiteral{Value: "a"}, Value: &PatternElement{Target: ident("a"), Default: one()}}}},
+			&HashPattern{Pairs: []*HashPatternPair{{Key: &StringLiteral{Value: "a"}, Value: &PatternElement{Target: ident("a"), Default: two()}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		require.Equal(t, tt.expected, modified)
+	}
+
+	hashLiteral := &HashLiteral{Pairs: map[Expression]Expression{one(): one()}}
+	Modify(hashLiteral, turnOneIntoTwo)
+	for key, value := range hashLiteral.Pairs {
+		require.Equal(t, int64(2), key.(*IntegerLiteral).Value)
+		require.Equal(t, int64(2), value.(*IntegerLiteral).Value)
+	}
+}
+
+func TestModifyRenamesParameterDefaults(t *testing.T) {
+	fl := &FunctionLiteral{
+		Parameters: []*Identifier{{Value: "x"}},
+		Defaults:   map[string]Expression{"x": &IntegerLiteral{Value: 1}},
+		Body:       &BlockStatement{},
+	}
+
+	Modify(fl, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &Identifier{Value: "y"}
+		}
+		return node
+	})
+	require.Equal(t, "y", fl.Parameters[0].Value)
+	require.Equal(t, map[string]Expression{"y": &IntegerLiteral{Value: 1}}, fl.Defaults)
+}
+
+func TestModifyIgnoresMisfitReplacement(t *testing.T) {
+	member := &MemberExpression{Object: &Identifier{Value: "p"}, Member: &Identifier{Value: "x"}}
+
+	Modify(member, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &IntegerLiteral{Value: 1}
+		}
+		return node
+	})
+	require.Equal(t, "x", member.Member.Value)
+}
+
+type collector struct {
+	visited *[]string
+}
+
+func (c collector) Visit(node Node) Visitor {
+	if node == nil {
+		*c.visited = append(*c.visited, "end")
+		return nil
+	}
+	switch node.(type) {
+	case *Identifier, *StringLiteral, *Boolean:
+		*c.visited = append(*c.visited, node.String())
+	default:
+		*c.visited = append(*c.visited, fmt.Sprintf("%T", node))
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &InfixExpression{
+			Left:     &Identifier{Value: "a"},
+			Operator: "+",
+			Right: &HashLiteral{Pairs: map[Expression]Expression{
+				&StringLiteral{Token: token.Token{Literal: "k2", Line: 1, Column: 9}}: &Boolean{Token: token.Token{Literal: "false"}},
+				&StringLiteral{Token: token.Token{Literal: "k1", Line: 1, Column: 2}}: &Boolean{Token: token.Token{Literal: "true"}},
+			}},
+		}},
+	}}
+
+	visited := []string{}
+	Walk(program, collector{&visited})
+	require.Equal(t, []string{
+		"*ast.Program",
+		"*ast.ExpressionStatement",
+		"*ast.InfixExpression",
+		"a", "end",
+		"*ast.HashLiteral",
+		"k1", "end", "true", "end", "k2", "end", "false", "end",
+		"end",
+		"end",
+		"end",
+		"end",
+	}, visited)
+}
+
+func TestInspect(t *testing.T) {
+	body := &BlockStatement{Statements: []Statement{
+		&ReturnStatement{ReturnValue: &Identifier{Value: "inner"}},
+	}}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "f"}, Value: &FunctionLiteral{Body: body}},
+		&ExpressionStatement{Expression: &CallExpression{Function: &Identifier{Value: "f"}}},
+	}}
+
+	identifiers := []string{}
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *FunctionLiteral:
+			// skip function bodies
+			return false
+		case *Identifier:
+			identifiers = append(identifiers, node.Value)
+		}
+		return true
+	})
+	require.Equal(t, []string{"f", "f"}, identifiers)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ReturnStatement{
+			Token: token.Token{Type: token.RETURN, Literal: "return", Line: 1, Column: 1},
+			ReturnValue: &HashLiteral{
+				Token: token.Token{Type: token.L_BRACE, Literal: "{", Line: 1, Column: 8},
+				Pairs: map[Expression]Expression{
+					&StringLiteral{Token: token.Token{Type: token.STRING, Literal: "a", Line: 1, Column: 9}, Value: "a"}: &BigIntegerLiteral{Value: big.NewInt(7)},
+				},
+			},
+		},
+	}}
+
+	data, err := MarshalJSON(program)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kind": "Program", "statements": [{
+		"kind": "ReturnStatement",
+		"token": {"type": "RETURN", "literal": "return", "line": 1, "column": 1},
+		"returnValue": {
+			"kind": "HashLiteral",
+			"token": {"type": "{", "literal": "{", "line": 1, "column": 8},
+			"pairs": [{
+				"key": {"kind": "StringLiteral", "token": {"type": "STRING", "literal": "a", "line": 1, "column": 9}, "value": "a"},
+				"value": {"kind": "BigIntegerLiteral", "token": {"type": "", "literal": "", "line": 0, "column": 0}, "value": "7"}
+			}]
+		}
+	}]}`, string(data))
+	require.True(t, strings.HasPrefix(string(data), `{"kind":"Program"`), "kind is not first in %s", data)
+
+	decoded, err := UnmarshalJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, program.String(), decoded.String())
+	again, err := MarshalJSON(decoded)
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(again))
+}
+
+func TestUnmarshalJSONError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind": "Nope"}`, `unknown node kind "Nope"`},
+		{`{"value": "x"}`, "missing node kind"},
+		{`{"kind": "LetStatement", "name": {"kind": "Boolean"}}`, "LetStatement: name: Boolean is not *ast.Identifier"},
+		{`{"kind": "BigIntegerLiteral", "value": "1x"}`, `BigIntegerLiteral: value: invalid integer "1x"`},
+		{`[]`, "cannot unmarshal array"},
+	}
+
+	for _, tt := range tests {
+		_, err := UnmarshalJSON([]byte(tt.input))
+		require.Error(t, err, tt.input)
+		require.Contains(t, err.Error(), tt.expected)
+	}
+}
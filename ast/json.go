@@ -0,0 +1,348 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// constructors of nodes by kind, used to decode JSON
+var nodeKinds = map[string]func() Node{
+	"Program":               func() Node { return &Program{} },
+	"ExpressionStatement":   func() Node { return &ExpressionStatement{} },
+	"BlockStatement":        func() Node { return &BlockStatement{} },
+	"LetStatement":          func() Node { return &LetStatement{} },
+	"ReturnStatement":       func() Node { return &ReturnStatement{} },
+	"ForStatement":          func() Node { return &ForStatement{} },
+	"BreakStatement":        func() Node { return &BreakStatement{} },
+	"ContinueStatement":     func() Node { return &ContinueStatement{} },
+	"YieldStatement":        func() Node { return &YieldStatement{} },
+	"StructStatement":       func() Node { return &StructStatement{} },
+	"AssignStatement":       func() Node { return &AssignStatement{} },
+	"Identifier":            func() Node { return &Identifier{} },
+	"IntegerLiteral":        func() Node { return &IntegerLiteral{} },
+	"BigIntegerLiteral":     func() Node { return &BigIntegerLiteral{} },
+	"StringLiteral":         func() Node { return &StringLiteral{} },
+	"Boolean":               func() Node { return &Boolean{} },
+	"PrefixExpression":      func() Node { return &PrefixExpression{} },
+	"InfixExpression":       func() Node { return &InfixExpression{} },
+	"IfExpression":          func() Node { return &IfExpression{} },
+	"FunctionLiteral":       func() Node { return &FunctionLiteral{} },
+	"CallExpression":        func() Node { return &CallExpression{} },
+	"ArrayLiteral":          func() Node { return &ArrayLiteral{} },
+	"IndexExpression":       func() Node { return &IndexExpression{} },
+	"MemberExpression":      func() Node { return &MemberExpression{} },
+	"HashLiteral":           func() Node { return &HashLiteral{} },
+	"SliceExpression":       func() Node { return &SliceExpression{} },
+	"InterpolatedString":    func() Node { return &InterpolatedString{} },
+	"SpreadExpression":      func() Node { return &SpreadExpression{} },
+	"ArrayPattern":          func() Node { return &ArrayPattern{} },
+	"HashPattern":           func() Node { return &HashPattern{} },
+	"PipeExpression":        func() Node { return &PipeExpression{} },
+	"ConditionalExpression": func() Node { return &ConditionalExpression{} },
+}
+
+var (
+	nodeType   = reflect.TypeOf((*Node)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// name of the type of node, like "InfixExpression"
+func Kind(node Node) string {
+	return reflect.Indirect(reflect.ValueOf(node)).Type().Name()
+}
+
+// encode node and its children as JSON objects. each node has its "kind"
+// first, followed by its fields named in lower camel case, like
+//
+//	{"kind":"Identifier","token":{"type":"IDENTIFIER","literal":"x","line":1,"column":5},"value":"x"}
+//
+// absent children are null, big integers are decimal strings and pairs of
+// hash literals are a list of {"key":...,"value":...} in source order
+func MarshalJSON(node Node) ([]byte, error) {
+	var out bytes.Buffer
+	if err := encodeValue(&out, reflect.ValueOf(&node).Elem()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func encodeValue(out *bytes.Buffer, v reflect.Value) error {
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encodeJSON(out, v.Interface().(*big.Int).String())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encodeValue(out, v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Struct && v.Type().Elem().PkgPath() == nodeType.PkgPath() {
+			return encodeStruct(out, v)
+		}
+		return encodeValue(out, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		out.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := encodeValue(out, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+		return nil
+	case reflect.Map:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		if pairs, ok := v.Interface().(map[Expression]Expression); ok {
+			return encodePairs(out, pairs)
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		out.WriteString("{")
+		for i, key := range keys {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := encodeJSON(out, key.String()); err != nil {
+				return err
+			}
+			out.WriteString(":")
+			if err := encodeValue(out, v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+		return nil
+	default:
+		return encodeJSON(out, v.Interface())
+	}
+}
+
+// encode pointer to node, PatternElement or HashPatternPair
+func encodeStruct(out *bytes.Buffer, v reflect.Value) error {
+	out.WriteString("{")
+	separator := ""
+	if v.Type().Implements(nodeType) {
+		out.WriteString(`"kind":`)
+		if err := encodeJSON(out, v.Elem().Type().Name()); err != nil {
+			return err
+		}
+		separator = ","
+	}
+
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		out.WriteString(separator)
+		separator = ","
+		if err := encodeJSON(out, fieldName(s.Type().Field(i))); err != nil {
+			return err
+		}
+		out.WriteString(":")
+		if err := encodeValue(out, s.Field(i)); err != nil {
+			return err
+		}
+	}
+	out.WriteString("}")
+	return nil
+}
+
+func encodePairs(out *bytes.Buffer, pairs map[Expression]Expression) error {
+	out.WriteString("[")
+	for i, key := range (&HashLiteral{Pairs: pairs}).Keys() {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString(`{"key":`)
+		if err := encodeValue(out, reflect.ValueOf(&key).Elem()); err != nil {
+			return err
+		}
+		out.WriteString(`,"value":`)
+		value := pairs[key]
+		if err := encodeValue(out, reflect.ValueOf(&value).Elem()); err != nil {
+			return err
+		}
+		out.WriteString("}")
+	}
+	out.WriteString("]")
+	return nil
+}
+
+func encodeJSON(out *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	out.Write(data)
+	return nil
+}
+
+// decode node encoded by MarshalJSON. fields missing from data are left zero
+func UnmarshalJSON(data []byte) (Node, error) {
+	return decodeNode(data)
+}
+
+func decodeNode(data json.RawMessage) (Node, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, nil
+	}
+
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return nil, fmt.Errorf("missing node kind: %w", err)
+	}
+	newNode, ok := nodeKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown node kind %q", kind)
+	}
+
+	node := newNode()
+	if err := decodeStruct(fields, reflect.ValueOf(node).Elem()); err != nil {
+		return nil, fmt.Errorf("%s: %w", kind, err)
+	}
+	return node, nil
+}
+
+func decodeStruct(fields map[string]json.RawMessage, s reflect.Value) error {
+	for i := 0; i < s.NumField(); i++ {
+		name := fieldName(s.Type().Field(i))
+		if data, ok := fields[name]; ok {
+			if err := decodeValue(data, s.Field(i)); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func decodeValue(data json.RawMessage, v reflect.Value) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Type() == bigIntType {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		value, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if v.Type().Implements(nodeType) {
+		node, err := decodeNode(data)
+		if err != nil {
+			return err
+		}
+		value := reflect.ValueOf(node)
+		if !value.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("%s is not %s", Kind(node), v.Type())
+		}
+		v.Set(value)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		value := reflect.New(v.Type().Elem())
+		if err := decodeStruct(fields, value.Elem()); err != nil {
+			return err
+		}
+		v.Set(value)
+		return nil
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		value := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(value)
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			var items map[string]json.RawMessage
+			if err := json.Unmarshal(data, &items); err != nil {
+				return err
+			}
+			value := reflect.MakeMapWithSize(v.Type(), len(items))
+			for key, item := range items {
+				element := reflect.New(v.Type().Elem()).Elem()
+				if err := decodeValue(item, element); err != nil {
+					return err
+				}
+				value.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), element)
+			}
+			v.Set(value)
+			return nil
+		}
+
+		var pairs []struct{ Key, Value json.RawMessage }
+		if err := json.Unmarshal(data, &pairs); err != nil {
+			return err
+		}
+		value := reflect.MakeMapWithSize(v.Type(), len(pairs))
+		for _, pair := range pairs {
+			key := reflect.New(v.Type().Key()).Elem()
+			element := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(pair.Key, key); err != nil {
+				return err
+			}
+			if err := decodeValue(pair.Value, element); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, element)
+		}
+		v.Set(value)
+		return nil
+	default:
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+}
+
+// name of struct field in JSON, like "returnValue" for ReturnValue
+func fieldName(field reflect.StructField) string {
+	first, size := utf8.DecodeRuneInString(field.Name)
+	return string(unicode.ToLower(first)) + field.Name[size:]
+}
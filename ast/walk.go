@@ -0,0 +1,328 @@
+package ast
+
+// Visitor is called by Walk for each node. Walk visits the children of node
+// with the returned visitor unless it is nil
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// traverse node in depth-first order. it calls v.Visit(node), walks each
+// child with the visitor w returned by it, then calls w.Visit(nil)
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, statement := range n.Statements {
+			Walk(statement, v)
+		}
+
+	// statements
+	case *ExpressionStatement:
+		walkExpression(n.Expression, v)
+	case *BlockStatement:
+		for _, statement := range n.Statements {
+			Walk(statement, v)
+		}
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		walkExpression(n.Pattern, v)
+		walkExpression(n.Value, v)
+	case *ReturnStatement:
+		walkExpression(n.ReturnValue, v)
+	case *ForStatement:
+		if n.Key != nil {
+			Walk(n.Key, v)
+		}
+		Walk(n.Value, v)
+		walkExpression(n.Iterable, v)
+		Walk(n.Body, v)
+	case *BreakStatement, *ContinueStatement:
+		// nothing to walk
+	case *YieldStatement:
+		walkExpression(n.Value, v)
+	case *StructStatement:
+		Walk(n.Name, v)
+		for _, field := range n.Fields {
+			Walk(field, v)
+		}
+	case *AssignStatement:
+		Walk(n.Target, v)
+		walkExpression(n.Value, v)
+
+	// expressions
+	case *Identifier, *IntegerLiteral, *BigIntegerLiteral, *StringLiteral, *Boolean:
+		// nothing to walk
+	case *PrefixExpression:
+		walkExpression(n.Right, v)
+	case *InfixExpression:
+		walkExpression(n.Left, v)
+		walkExpression(n.Right, v)
+	case *IfExpression:
+		walkExpression(n.Condition, v)
+		Walk(n.Consequence, v)
+		if n.Alternative != nil {
+			Walk(n.Alternative, v)
+		}
+	case *FunctionLiteral:
+		for _, parameter := range n.Parameters {
+			Walk(parameter, v)
+			walkExpression(n.Defaults[parameter.Value], v)
+		}
+		if n.Rest != nil {
+			Walk(n.Rest, v)
+		}
+		Walk(n.Body, v)
+	case *CallExpression:
+		walkExpression(n.Function, v)
+		for _, argument := range n.Arguments {
+			walkExpression(argument, v)
+		}
+	case *ArrayLiteral:
+		for _, element := range n.Elements {
+			walkExpression(element, v)
+		}
+	case *IndexExpression:
+		walkExpression(n.Left, v)
+		walkExpression(n.Index, v)
+	case *MemberExpression:
+		walkExpression(n.Object, v)
+		Walk(n.Member, v)
+	case *HashLiteral:
+		for _, key := range n.Keys() {
+			walkExpression(key, v)
+			walkExpression(n.Pairs[key], v)
+		}
+	case *SliceExpression:
+		walkExpression(n.Left, v)
+		walkExpression(n.Start, v)
+		walkExpression(n.End, v)
+	case *InterpolatedString:
+		for _, part := range n.Parts {
+			walkExpression(part, v)
+		}
+	case *SpreadExpression:
+		walkExpression(n.Value, v)
+	case *ArrayPattern:
+		for _, element := range n.Elements {
+			walkPatternElement(element, v)
+		}
+		if n.Rest != nil {
+			Walk(n.Rest, v)
+		}
+	case *HashPattern:
+		for _, pair := range n.Pairs {
+			Walk(pair.Key, v)
+			walkPatternElement(pair.Value, v)
+		}
+		if n.Rest != nil {
+			Walk(n.Rest, v)
+		}
+	case *PipeExpression:
+		walkExpression(n.Left, v)
+		walkExpression(n.Right, v)
+	case *ConditionalExpression:
+		walkExpression(n.Condition, v)
+		walkExpression(n.Consequence, v)
+		walkExpression(n.Alternative, v)
+	}
+
+	v.Visit(nil)
+}
+
+// walk optional expression
+func walkExpression(e Expression, v Visitor) {
+	if e != nil {
+		Walk(e, v)
+	}
+}
+
+func walkPatternElement(element *PatternElement, v Visitor) {
+	walkExpression(element.Target, v)
+	walkExpression(element.Default, v)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// traverse node in depth-first order, calling f for each node and then
+// f(nil) after its children. children are skipped if f returns false
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// ModifierFunc returns the replacement of node
+type ModifierFunc func(Node) Node
+
+// rewrite children of node in depth-first order and return modifier(node).
+// a replacement which does not fit the field it replaces is ignored
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		for i, statement := range n.Statements {
+			n.Statements[i] = modifyStatement(statement, modifier)
+		}
+
+	// statements
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *BlockStatement:
+		for i, statement := range n.Statements {
+			n.Statements[i] = modifyStatement(statement, modifier)
+		}
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Pattern = modifyExpression(n.Pattern, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ForStatement:
+		n.Key = modifyIdentifier(n.Key, modifier)
+		n.Value = modifyIdentifier(n.Value, modifier)
+		n.Iterable = modifyExpression(n.Iterable, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *YieldStatement:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *StructStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		for i, field := range n.Fields {
+			n.Fields[i] = modifyIdentifier(field, modifier)
+		}
+	case *AssignStatement:
+		if target, ok := Modify(n.Target, modifier).(*MemberExpression); ok {
+			n.Target = target
+		}
+		n.Value = modifyExpression(n.Value, modifier)
+
+	// expressions
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *FunctionLiteral:
+		for i, parameter := range n.Parameters {
+			name := parameter.Value
+			n.Parameters[i] = modifyIdentifier(parameter, modifier)
+			// defaults follow renamed parameters
+			if value, ok := n.Defaults[name]; ok {
+				delete(n.Defaults, name)
+				n.Defaults[n.Parameters[i].Value] = modifyExpression(value, modifier)
+			}
+		}
+		n.Rest = modifyIdentifier(n.Rest, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		for i, argument := range n.Arguments {
+			n.Arguments[i] = modifyExpression(argument, modifier)
+		}
+	case *ArrayLiteral:
+		for i, element := range n.Elements {
+			n.Elements[i] = modifyExpression(element, modifier)
+		}
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *MemberExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Member = modifyIdentifier(n.Member, modifier)
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(n.Pairs))
+		for _, key := range n.Keys() {
+			pairs[modifyExpression(key, modifier)] = modifyExpression(n.Pairs[key], modifier)
+		}
+		n.Pairs = pairs
+	case *SliceExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Start = modifyExpression(n.Start, modifier)
+		n.End = modifyExpression(n.End, modifier)
+	case *InterpolatedString:
+		for i, part := range n.Parts {
+			n.Parts[i] = modifyExpression(part, modifier)
+		}
+	case *SpreadExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ArrayPattern:
+		for _, element := range n.Elements {
+			modifyPatternElement(element, modifier)
+		}
+		n.Rest = modifyIdentifier(n.Rest, modifier)
+	case *HashPattern:
+		for _, pair := range n.Pairs {
+			if key, ok := Modify(pair.Key, modifier).(*StringLiteral); ok {
+				pair.Key = key
+			}
+			modifyPatternElement(pair.Value, modifier)
+		}
+		n.Rest = modifyIdentifier(n.Rest, modifier)
+	case *PipeExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *ConditionalExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyExpression(n.Consequence, modifier)
+		n.Alternative = modifyExpression(n.Alternative, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatement(s Statement, modifier ModifierFunc) Statement {
+	if modified, ok := Modify(s, modifier).(Statement); ok {
+		return modified
+	}
+	return s
+}
+
+// modify optional expression
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	if modified, ok := Modify(e, modifier).(Expression); ok {
+		return modified
+	}
+	return e
+}
+
+// modify optional identifier
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	if i == nil {
+		return nil
+	}
+	if modified, ok := Modify(i, modifier).(*Identifier); ok {
+		return modified
+	}
+	return i
+}
+
+// modify optional block
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	if modified, ok := Modify(b, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return b
+}
+
+func modifyPatternElement(element *PatternElement, modifier ModifierFunc) {
+	element.Target = modifyExpression(element.Target, modifier)
+	element.Default = modifyExpression(element.Default, modifier)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/token"
+	"os"
+	"strings"
+)
+
+// subcommands by name, taking arguments after the name and returning exit status
+var commands = map[string]func(args []string) int{
+	"fmt":    formatCommand,
+	"tokens": tokensCommand,
+	"ast":    astCommand,
+}
+
+// format files in place or to stdout, or stdin if no file is given
+func formatCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to the file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s fmt [-w] [files...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return 2
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", string(source), false)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if s := formatFile(path, string(source), *write); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+func formatFile(path string, source string, write bool) int {
+	formatted, err := format.Source(source)
+	var parseError *format.ParseError
+	if errors.As(err, &parseError) {
+		fmt.Fprintf(os.Stderr, "%s:\n%s", path, repl.Renderer.RenderAll(source, parseError.Diagnostics))
+		return 1
+	}
+
+	if !write {
+		fmt.Print(formatted)
+		return 0
+	}
+	if formatted == source {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// print tokens of a file or stdin, one per line or as a JSON array
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print tokens as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s tokens [-json] [file]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	input, err := openInput(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer input.Close()
+
+	l := lexer.NewReader(input)
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if err := l.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *asJSON {
+		return printJSON(tokens)
+	}
+	for _, tok := range tokens {
+		fmt.Printf("%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+	return 0
+}
+
+// print syntax tree of a file or stdin, indented or as JSON
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tree as JSON with node kinds and positions")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s ast [-json] [file]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	input, err := openInput(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer input.Close()
+	source, err := io.ReadAll(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprint(os.Stderr, repl.Renderer.RenderAll(string(source), p.Diagnostics()))
+		return 1
+	}
+
+	if *asJSON {
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return printJSON(json.RawMessage(data))
+	}
+
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return true
+		}
+		tok := ast.NodeToken(node)
+		line := strings.Repeat("  ", depth) + ast.Kind(node)
+		if tok.Line > 0 {
+			line += fmt.Sprintf(" %d:%d %q", tok.Line, tok.Column, tok.Literal)
+		}
+		fmt.Println(line)
+		depth++
+		return true
+	})
+	return 0
+}
+
+// file named by the only argument of flags, or stdin if there is none
+func openInput(flags *flag.FlagSet) (io.ReadCloser, error) {
+	switch flags.NArg() {
+	case 0:
+		return io.NopCloser(os.Stdin), nil
+	case 1:
+		return os.Open(flags.Arg(0))
+	default:
+		flags.Usage()
+		os.Exit(2)
+		return nil, nil
+	}
+}
+
+func printJSON(v interface{}) int {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,114 @@
+package diagnostic
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/object"
+	"monkey/parser"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences
+const (
+	reset = "\x1b[0m"
+	bold  = "\x1b[1m"
+	red   = "\x1b[31m"
+	blue  = "\x1b[34m"
+	cyan  = "\x1b[36m"
+)
+
+// prints diagnostics with the offending source line and a caret under the
+// column. output is plain text for logs unless Color enables ANSI escapes
+type Renderer struct {
+	Color bool
+}
+
+// render a diagnostic found in source, like
+//
+//	error: expected next token to be ), got ; instead
+//	 --> 1:15
+//	  |
+//	1 | let x = (1 + 2;
+//	  |               ^
+//	  = help: insert ")" after "2"
+func (r Renderer) Render(source string, d parser.Diagnostic) string {
+	var out bytes.Buffer
+
+	out.WriteString(r.paint(bold+red, d.Severity.String()))
+	out.WriteString(r.paint(bold, ": "+d.Message))
+	out.WriteString("\n")
+
+	if line, ok := sourceLine(source, d.Line); ok && d.Column > 0 {
+		number := strconv.Itoa(d.Line)
+		gutter := strings.Repeat(" ", len(number))
+
+		out.WriteString(fmt.Sprintf("%s%s %d:%d\n", gutter, r.paint(blue, "-->"), d.Line, d.Column))
+		out.WriteString(r.paint(blue, gutter+" |") + "\n")
+		out.WriteString(r.paint(blue, number+" |") + " " + line + "\n")
+		out.WriteString(r.paint(blue, gutter+" |") + " " + caretIndent(line, d.Column) + r.paint(bold+red, "^") + "\n")
+		if d.Suggestion != "" {
+			out.WriteString(r.paint(blue, gutter+" =") + " " + r.paint(cyan, "help: "+d.Suggestion) + "\n")
+		}
+	} else if d.Suggestion != "" {
+		out.WriteString(r.paint(cyan, "help: "+d.Suggestion) + "\n")
+	}
+
+	return out.String()
+}
+
+// render every diagnostic found in source
+func (r Renderer) RenderAll(source string, diagnostics []parser.Diagnostic) string {
+	var out bytes.Buffer
+	for _, d := range diagnostics {
+		out.WriteString(r.Render(source, d))
+	}
+	return out.String()
+}
+
+// render runtime error raised while evaluating source
+func (r Renderer) RenderError(source string, err *object.Error) string {
+	return r.Render(source, parser.Diagnostic{
+		Severity: parser.SeverityError,
+		Line:     err.Line,
+		Column:   err.Column,
+		Message:  err.Message,
+	})
+}
+
+func (r Renderer) paint(style, s string) string {
+	if !r.Color {
+		return s
+	}
+	return style + s + reset
+}
+
+// 1-based line of source without its line break
+func sourceLine(source string, line int) (string, bool) {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+// blank prefix of line up to the 1-based rune column, keeping tabs so the
+// caret lines up with the source
+func caretIndent(line string, column int) string {
+	var out strings.Builder
+	for i, ch := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	// columns past the end of line point at EOF or the line break
+	for i := len([]rune(line)); i < column-1; i++ {
+		out.WriteRune(' ')
+	}
+	return out.String()
+}
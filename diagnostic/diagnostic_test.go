@@ -0,0 +1,93 @@
+package diagnostic
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = (1 + 2;",
+			`error: expected next token to be ), got ; instead
+ --> 1:15
+  |
+1 | let x = (1 + 2;
+  |               ^
+  = help: insert ")" after "2"
+`,
+		},
+		{
+			"let a = 1;\n\tlet b = );",
+			`error: no prefix parse function for ) found
+ --> 2:10
+  |
+2 | 	let b = );
+  | 	        ^
+  = help: remove the unmatched ")"
+`,
+		},
+		{
+			"1 +",
+			`error: no prefix parse function for EOF found
+ --> 1:4
+  |
+1 | 1 +
+  |    ^
+  = help: the input ends before the expression is complete
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		require.Equal(t, tt.expected, Renderer{}.RenderAll(tt.input, p.Diagnostics()))
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	d := parser.Diagnostic{Severity: parser.SeverityError, Line: 1, Column: 2, Message: "careful"}
+	expected := "\x1b[1m\x1b[31merror\x1b[0m\x1b[1m: careful\x1b[0m\n" +
+		" \x1b[34m-->\x1b[0m 1:2\n" +
+		"\x1b[34m  |\x1b[0m\n" +
+		"\x1b[34m1 |\x1b[0m ab\n" +
+		"\x1b[34m  |\x1b[0m  \x1b[1m\x1b[31m^\x1b[0m\n"
+	require.Equal(t, expected, Renderer{Color: true}.Render("ab", d))
+}
+
+func TestRenderError(t *testing.T) {
+	tests := []struct {
+		err      *object.Error
+		expected string
+	}{
+		{
+			&object.Error{Message: "identifier not found: y", Line: 2, Column: 5},
+			`error: identifier not found: y
+ --> 2:5
+  |
+2 | x + y
+  |     ^
+`,
+		},
+		{
+			&object.Error{Message: "break outside of loop"},
+			"error: break outside of loop\n",
+		},
+		{
+			&object.Error{Message: "out of source", Line: 9, Column: 1},
+			"error: out of source\n",
+		},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, Renderer{}.RenderError("let x = 1;\nx + y", tt.err))
+	}
+}
@@ -1,165 +1,1042 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/object"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{}
 
+// object types accepted by parameters of builtins
+var (
+	anyType      = []object.ObjectType{}
+	arrayType    = []object.ObjectType{object.ARRAY_OBJ}
+	hashType     = []object.ObjectType{object.HASH_OBJ}
+	stringType   = []object.ObjectType{object.STRING_OBJ}
+	integerType  = []object.ObjectType{object.INTEGER_OBJ}
+	callableType = []object.ObjectType{object.FUNCTION_OBJ, object.BUILTIN_OBJ, object.METHOD_OBJ}
+	iterableType = []object.ObjectType{object.ARRAY_OBJ, object.HASH_OBJ, object.STRING_OBJ, object.ITERATOR_OBJ, object.CHANNEL_OBJ}
+	taskType     = []object.ObjectType{object.TASK_OBJ}
+	channelType  = []object.ObjectType{object.CHANNEL_OBJ}
+)
+
+// register a built-in function
+//
+// arguments are validated against signature before fn is called
+func registerBuiltin(signature object.BuiltinSignature, fn object.BuiltinFunction) {
+	builtins[signature.Name] = &object.Builtin{Signature: &signature, Fn: fn}
+}
+
+// builtins acting on the interpreter which calls them, by name. they are
+// bound to the environment of the caller when they are looked up
+var environmentBuiltins = map[string]func(env *object.Environment) object.BuiltinFunction{}
+
+// register a built-in function which needs the environment of its caller
+func registerEnvironmentBuiltin(signature object.BuiltinSignature, bind func(env *object.Environment) object.BuiltinFunction) {
+	environmentBuiltins[signature.Name] = bind
+	registerBuiltin(signature, func(args ...object.Object) object.Object {
+		return newError("%s must be called by name", signature.Name)
+	})
+}
+
+// return builtin name bound to env, if there is one
+func lookupBuiltin(name string, env *object.Environment) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	if bind, ok := environmentBuiltins[name]; ok {
+		return &object.Builtin{Signature: builtin.Signature, Fn: bind(env)}, true
+	}
+	return builtin, true
+}
+
+func param(name string, types []object.ObjectType) object.BuiltinParameter {
+	return object.BuiltinParameter{Name: name, Types: types}
+}
+
+func optionalParam(name string, types []object.ObjectType) object.BuiltinParameter {
+	return object.BuiltinParameter{Name: name, Types: types, Optional: true}
+}
+
 func init() {
 	// to avoid initialization loop
-	builtins["len"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "len",
+		Parameters: []object.BuiltinParameter{param("value", anyType)},
+		Doc:        "return the number of characters of a string, elements of an array or pairs of a hash",
+	}, func(args ...object.Object) object.Object {
+		switch arg := args[0].(type) {
+		case *object.Array:
+			return &object.Integer{Value: int64(len(arg.Elements))}
+		case *object.Hash:
+			return &object.Integer{Value: int64(len(arg.Pairs))}
+		case *object.String:
+			return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+		default:
+			return newError("argument to len not supported, got %s", args[0].Type())
+		}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "type",
+		Parameters: []object.BuiltinParameter{param("value", anyType)},
+		Doc:        "return the type of value",
+	}, func(args ...object.Object) object.Object {
+		if s, ok := args[0].(*object.Struct); ok {
+			return &object.ObjectTypeObject{Value: s.Type(), Struct: s.Definition}
+		}
+		return &object.ObjectTypeObject{Value: args[0].Type()}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "puts",
+		Parameters: []object.BuiltinParameter{optionalParam("values", anyType)},
+		Variadic:   true,
+		Doc:        "print each value on its own line",
+	}, func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			fmt.Println(arg.Inspect())
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "help",
+		Parameters: []object.BuiltinParameter{optionalParam("fn", callableType)},
+		Doc:        "describe fn, or list all built-in functions",
+	}, func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return &object.String{Value: builtinsHelp()}
+		}
+		if builtin, ok := args[0].(*object.Builtin); ok && builtin.Signature != nil {
+			return &object.String{Value: builtin.Signature.String() + "\n\t" + builtin.Signature.Doc}
+		}
+		return &object.String{Value: args[0].Inspect()}
+	})
+
+	// array
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "first",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return the first element of arr, or null if arr is empty",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		if len(arr.Elements) > 0 {
+			return arr.Elements[0]
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "last",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return the last element of arr, or null if arr is empty",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		length := len(arr.Elements)
+		if length > 0 {
+			return arr.Elements[length-1]
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "rest",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return a new array without the first element of arr",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		length := len(arr.Elements)
+		if length == 0 {
+			return NULL
+		}
+		newElements := make([]object.Object, length-1)
+		copy(newElements, arr.Elements[1:length])
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "push",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("value", anyType)},
+		Doc:        "return a new array with value appended to arr",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		length := len(arr.Elements)
+		newElements := make([]object.Object, length+1)
+		copy(newElements, arr.Elements)
+		newElements[length] = args[1]
+
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "map",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("fn", callableType)},
+		Doc:        "return a new array with fn applied to each element of arr",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		newElements := make([]object.Object, len(arr.Elements))
+
+		for index, element := range arr.Elements {
+			result := applyFunction(args[1], []object.Object{element})
+			if isError(result) {
+				return result
 			}
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			default:
-				return newError("argument to len not supported, got %s", args[0].Type())
-			}
-		},
-	}
-	builtins["type"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			newElements[index] = result
+		}
+
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "reduce",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("initial", anyType), param("fn", callableType)},
+		Doc:        "fold arr from the left with fn, starting from initial",
+	}, func(args ...object.Object) object.Object {
+		accumulated := args[1]
+		for _, element := range args[0].(*object.Array).Elements {
+			accumulated = applyFunction(args[2], []object.Object{accumulated, element})
+			if isError(accumulated) {
+				return accumulated
 			}
-			return &object.ObjectTypeObject{Value: args[0].Type()}
-		},
-	}
-	builtins["puts"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+		}
+
+		return accumulated
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "filter",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("fn", callableType)},
+		Doc:        "return a new array with the elements of arr for which fn is truthy",
+	}, func(args ...object.Object) object.Object {
+		newElements := []object.Object{}
+		for _, element := range args[0].(*object.Array).Elements {
+			result := applyFunction(args[1], []object.Object{element})
+			if isError(result) {
+				return result
 			}
-			return NULL
-		},
-	}
-	builtins["first"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			if isTruthy(result) {
+				newElements = append(newElements, element)
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "find",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("fn", callableType)},
+		Doc:        "return the first element of arr for which fn is truthy, or null",
+	}, func(args ...object.Object) object.Object {
+		for _, element := range args[0].(*object.Array).Elements {
+			result := applyFunction(args[1], []object.Object{element})
+			if isError(result) {
+				return result
 			}
-
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if isTruthy(result) {
+				return element
 			}
-			return NULL
-		},
-	}
-	builtins["last"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "any",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("fn", callableType)},
+		Doc:        "return true if fn is truthy for any element of arr",
+	}, func(args ...object.Object) object.Object {
+		for _, element := range args[0].(*object.Array).Elements {
+			result := applyFunction(args[1], []object.Object{element})
+			if isError(result) {
+				return result
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+			if isTruthy(result) {
+				return TRUE
 			}
+		}
+		return FALSE
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "all",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("fn", callableType)},
+		Doc:        "return true if fn is truthy for every element of arr",
+	}, func(args ...object.Object) object.Object {
+		for _, element := range args[0].(*object.Array).Elements {
+			result := applyFunction(args[1], []object.Object{element})
+			if isError(result) {
+				return result
+			}
+			if !isTruthy(result) {
+				return FALSE
+			}
+		}
+		return TRUE
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "sort",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), optionalParam("comparator", callableType)},
+		Doc:        "return a sorted copy of arr; comparator(a, b) returns true or a negative integer if a goes first",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		newElements := make([]object.Object, len(arr.Elements))
+		copy(newElements, arr.Elements)
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
+		// the first error raised by the comparator aborts sorting
+		var err object.Object
+		sort.SliceStable(newElements, func(i, j int) bool {
+			if err != nil {
+				return false
 			}
-			return NULL
-		},
-	}
-	builtins["rest"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			if len(args) == 1 {
+				cmp, ok := object.Compare(newElements[i], newElements[j])
+				if !ok {
+					err = newError("unable to compare %s and %s", newElements[i].Type(), newElements[j].Type())
+					return false
+				}
+				return cmp < 0
+			}
+
+			result := applyFunction(args[1], []object.Object{newElements[i], newElements[j]})
+			if isError(result) {
+				err = result
+				return false
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+			if integer, ok := object.ToBigInt(result); ok {
+				return integer.Sign() < 0
 			}
+			return isTruthy(result)
+		})
+		if err != nil {
+			return err
+		}
+
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "reverse",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return a reversed copy of arr",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		length := len(arr.Elements)
+		newElements := make([]object.Object, length)
+		for index, element := range arr.Elements {
+			newElements[length-index-1] = element
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "slice",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), param("start", integerType), optionalParam("end", integerType)},
+		Doc:        "return the elements of arr from start up to end; negative indices count from the end",
+	}, func(args ...object.Object) object.Object {
+		arr := args[0].(*object.Array)
+		length := int64(len(arr.Elements))
+		start := clampIndex(args[1].(*object.Integer).Value, length)
+		end := length
+		if len(args) == 3 {
+			end = clampIndex(args[2].(*object.Integer).Value, length)
+		}
+		if start > end {
+			start = end
+		}
+
+		newElements := make([]object.Object, end-start)
+		copy(newElements, arr.Elements[start:end])
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "concat",
+		Parameters: []object.BuiltinParameter{optionalParam("arrays", arrayType)},
+		Variadic:   true,
+		Doc:        "return a new array with the elements of all arrays",
+	}, func(args ...object.Object) object.Object {
+		newElements := []object.Object{}
+		for _, arg := range args {
+			newElements = append(newElements, arg.(*object.Array).Elements...)
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "range",
+		Parameters: []object.BuiltinParameter{param("start", integerType), optionalParam("end", integerType), optionalParam("step", integerType)},
+		Doc:        "return an array of integers from start up to end; range(n) counts from 0 to n",
+	}, func(args ...object.Object) object.Object {
+		var start, end, step int64 = 0, 0, 1
+		switch len(args) {
+		case 1:
+			end = args[0].(*object.Integer).Value
+		case 2:
+			start = args[0].(*object.Integer).Value
+			end = args[1].(*object.Integer).Value
+		case 3:
+			start = args[0].(*object.Integer).Value
+			end = args[1].(*object.Integer).Value
+			step = args[2].(*object.Integer).Value
+		}
+		if step == 0 {
+			return newError("range step must not be zero")
+		}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length-1)
-			if length > 0 {
-				copy(newElements, arr.Elements[1:length])
+		// count elements in unsigned arithmetic, which cannot overflow
+		var count uint64
+		if step > 0 && start < end {
+			count = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+		} else if step < 0 && start > end {
+			count = (uint64(start)-uint64(end)-1)/(uint64(-(step+1))+1) + 1
+		}
+		if count > maxRangeLength {
+			return newError("range is too long, limit is %d elements", maxRangeLength)
+		}
+
+		newElements := make([]object.Object, count)
+		for index := range newElements {
+			newElements[index] = &object.Integer{Value: start + int64(index)*step}
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "zip",
+		Parameters: []object.BuiltinParameter{param("arrays", arrayType)},
+		Variadic:   true,
+		Doc:        "return an array of arrays pairing up elements of arrays, as long as the shortest one",
+	}, func(args ...object.Object) object.Object {
+		length := -1
+		for _, arg := range args {
+			if l := len(arg.(*object.Array).Elements); length < 0 || l < length {
+				length = l
 			}
-			return &object.Array{Elements: newElements}
-		},
-	}
-	builtins["push"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		newElements := make([]object.Object, length)
+		for index := range newElements {
+			tuple := make([]object.Object, len(args))
+			for argIndex, arg := range args {
+				tuple[argIndex] = arg.(*object.Array).Elements[index]
+			}
+			newElements[index] = &object.Array{Elements: tuple}
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "flatten",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return a new array with nested arrays of arr flattened by one level",
+	}, func(args ...object.Object) object.Object {
+		newElements := []object.Object{}
+		for _, element := range args[0].(*object.Array).Elements {
+			if inner, ok := element.(*object.Array); ok {
+				newElements = append(newElements, inner.Elements...)
+			} else {
+				newElements = append(newElements, element)
+			}
+		}
+		return &object.Array{Elements: newElements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "unique",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType)},
+		Doc:        "return a new array without duplicated elements of arr, keeping the first occurrence",
+	}, func(args ...object.Object) object.Object {
+		seen := make(map[object.HashKey]bool)
+		newElements := []object.Object{}
+		for _, element := range args[0].(*object.Array).Elements {
+			key, ok := element.(object.Hashable)
+			if !ok {
+				return newError("unhashable as hash key: %s", element.Type())
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+			if hashed := key.HashKey(); !seen[hashed] {
+				seen[hashed] = true
+				newElements = append(newElements, element)
 			}
+		}
+		return &object.Array{Elements: newElements}
+	})
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
+	// hash
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "keys",
+		Parameters: []object.BuiltinParameter{param("hash", hashType)},
+		Doc:        "return the keys of hash as a sorted array",
+	}, func(args ...object.Object) object.Object {
+		pairs := args[0].(*object.Hash).SortedPairs()
+		elements := make([]object.Object, len(pairs))
+		for index, pair := range pairs {
+			elements[index] = pair.Key
+		}
+		return &object.Array{Elements: elements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "values",
+		Parameters: []object.BuiltinParameter{param("hash", hashType)},
+		Doc:        "return the values of hash, ordered by their keys",
+	}, func(args ...object.Object) object.Object {
+		pairs := args[0].(*object.Hash).SortedPairs()
+		elements := make([]object.Object, len(pairs))
+		for index, pair := range pairs {
+			elements[index] = pair.Value
+		}
+		return &object.Array{Elements: elements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "items",
+		Parameters: []object.BuiltinParameter{param("hash", hashType)},
+		Doc:        "return [key, value] pairs of hash, ordered by their keys",
+	}, func(args ...object.Object) object.Object {
+		pairs := args[0].(*object.Hash).SortedPairs()
+		elements := make([]object.Object, len(pairs))
+		for index, pair := range pairs {
+			elements[index] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+		}
+		return &object.Array{Elements: elements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "has",
+		Parameters: []object.BuiltinParameter{param("hash", hashType), param("key", anyType)},
+		Doc:        "return true if hash contains key",
+	}, func(args ...object.Object) object.Object {
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unhashable as hash key: %s", args[1].Type())
+		}
 
-			return &object.Array{Elements: newElements}
-		},
-	}
-	builtins["map"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+		_, ok = args[0].(*object.Hash).Pairs[key.HashKey()]
+		return nativeBoolToBooleanObject(ok)
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "delete",
+		Parameters: []object.BuiltinParameter{param("hash", hashType), param("key", anyType)},
+		Doc:        "return a copy of hash without key",
+	}, func(args ...object.Object) object.Object {
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unhashable as hash key: %s", args[1].Type())
+		}
+
+		hash := args[0].(*object.Hash)
+		hashed := key.HashKey()
+		pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+		for hashKey, pair := range hash.Pairs {
+			if hashKey != hashed {
+				pairs[hashKey] = pair
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+		}
+		return &object.Hash{Pairs: pairs}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "merge",
+		Parameters: []object.BuiltinParameter{param("hashes", hashType)},
+		Variadic:   true,
+		Doc:        "return a new hash with the pairs of all hashes; later hashes win",
+	}, func(args ...object.Object) object.Object {
+		pairs := make(map[object.HashKey]object.HashPair)
+		for _, arg := range args {
+			for hashKey, pair := range arg.(*object.Hash).Pairs {
+				pairs[hashKey] = pair
 			}
-			if args[1].Type() != object.FUNCTION_OBJ {
-				return newError("argument to second must be FUNCTION, got %s", args[1].Type())
+		}
+		return &object.Hash{Pairs: pairs}
+	})
+
+	// iterator
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "iterate",
+		Parameters: []object.BuiltinParameter{param("fn", callableType), param("seed", anyType)},
+		Doc:        "return an endless iterator of seed, fn(seed), fn(fn(seed)), ...",
+	}, func(args ...object.Object) object.Object {
+		var current object.Object
+		failed := false
+		return &object.LazyIterator{NextFn: func() (object.Object, bool) {
+			if failed {
+				return nil, false
+			}
+			if current == nil {
+				current = args[1]
+			} else {
+				current = applyFunction(args[0], []object.Object{current})
+				failed = isError(current)
 			}
+			return current, true
+		}}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "lazyMap",
+		Parameters: []object.BuiltinParameter{param("iterable", iterableType), param("fn", callableType)},
+		Doc:        "return an iterator applying fn to each item of iterable on demand",
+	}, func(args ...object.Object) object.Object {
+		source := args[0].(object.Iterable).Iterator()
+		return &object.LazyIterator{
+			NextFn: func() (object.Object, bool) {
+				item, ok := source.Next()
+				if !ok || isError(item) {
+					return item, ok
+				}
+				return applyFunction(args[1], []object.Object{item}), true
+			},
+			CloseFn: closeIterator(source),
+		}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "lazyFilter",
+		Parameters: []object.BuiltinParameter{param("iterable", iterableType), param("fn", callableType)},
+		Doc:        "return an iterator of the items of iterable for which fn is truthy",
+	}, func(args ...object.Object) object.Object {
+		source := args[0].(object.Iterable).Iterator()
+		return &object.LazyIterator{
+			NextFn: func() (object.Object, bool) {
+				for {
+					item, ok := source.Next()
+					if !ok || isError(item) {
+						return item, ok
+					}
+					result := applyFunction(args[1], []object.Object{item})
+					if isError(result) {
+						return result, true
+					}
+					if isTruthy(result) {
+						return item, true
+					}
+				}
+			},
+			CloseFn: closeIterator(source),
+		}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "take",
+		Parameters: []object.BuiltinParameter{param("iterable", iterableType), param("n", integerType)},
+		Doc:        "return an iterator of the first n items of iterable",
+	}, func(args ...object.Object) object.Object {
+		source := args[0].(object.Iterable).Iterator()
+		remaining := args[1].(*object.Integer).Value
+		closeSource := closeIterator(source)
+		return &object.LazyIterator{
+			NextFn: func() (object.Object, bool) {
+				if remaining <= 0 {
+					closeSource()
+					return nil, false
+				}
+				remaining--
+				return source.Next()
+			},
+			CloseFn: closeSource,
+		}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "collect",
+		Parameters: []object.BuiltinParameter{param("iterable", iterableType)},
+		Doc:        "return the items of iterable as an array",
+	}, func(args ...object.Object) object.Object {
+		iterator := args[0].(object.Iterable).Iterator()
+		defer closeIterator(iterator)()
 
-			arr := args[0].(*object.Array)
-			fn := args[1].(*object.Function)
+		elements := []object.Object{}
+		for item, ok := iterator.Next(); ok; item, ok = iterator.Next() {
+			if isError(item) {
+				return item
+			}
+			elements = append(elements, item)
+		}
+		return &object.Array{Elements: elements}
+	})
 
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length)
+	// concurrency
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "spawn",
+		Parameters: []object.BuiltinParameter{param("fn", callableType), optionalParam("args", anyType)},
+		Variadic:   true,
+		Doc:        "call fn with args in a new task and return the task without waiting",
+	}, func(args ...object.Object) object.Object {
+		fn, fnArgs := args[0], args[1:]
+		return object.NewTask(func() object.Object {
+			return applyFunction(fn, fnArgs)
+		})
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "await",
+		Parameters: []object.BuiltinParameter{param("task", taskType)},
+		Doc:        "wait until task finishes and return its result",
+	}, func(args ...object.Object) object.Object {
+		return args[0].(*object.Task).Await()
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "channel",
+		Parameters: []object.BuiltinParameter{optionalParam("size", integerType)},
+		Doc:        "return a new channel buffering up to size values, unbuffered by default",
+	}, func(args ...object.Object) object.Object {
+		size := int64(0)
+		if len(args) == 1 {
+			size = args[0].(*object.Integer).Value
+		}
+		if size < 0 {
+			return newError("channel size must not be negative, got %d", size)
+		}
+		if size > maxChannelSize {
+			return newError("channel size must not exceed %d, got %d", maxChannelSize, size)
+		}
+		return object.NewChannel(int(size))
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "send",
+		Parameters: []object.BuiltinParameter{param("ch", channelType), param("value", anyType)},
+		Doc:        "send value to ch, waiting while its buffer is full",
+	}, func(args ...object.Object) object.Object {
+		if !args[0].(*object.Channel).Send(args[1]) {
+			return newError("send on closed channel")
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "recv",
+		Parameters: []object.BuiltinParameter{param("ch", channelType)},
+		Doc:        "receive a value from ch, waiting while it is empty; null once ch is closed",
+	}, func(args ...object.Object) object.Object {
+		value, ok := args[0].(*object.Channel).Recv()
+		if !ok {
+			return NULL
+		}
+		return value
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "close",
+		Parameters: []object.BuiltinParameter{param("ch", channelType)},
+		Doc:        "close ch; values already sent can still be received",
+	}, func(args ...object.Object) object.Object {
+		if !args[0].(*object.Channel).Close() {
+			return newError("close of closed channel")
+		}
+		return NULL
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "select",
+		Parameters: []object.BuiltinParameter{param("channels", arrayType)},
+		Doc:        "receive from the first ready of channels and return [index, value]; value is null if that channel is closed",
+	}, func(args ...object.Object) object.Object {
+		elements := args[0].(*object.Array).Elements
+		if len(elements) == 0 {
+			return newError("select needs at least one channel")
+		}
 
-			for index, element := range arr.Elements {
-				newElements[index] = applyFunction(fn, []object.Object{element})
+		channels := make([]*object.Channel, len(elements))
+		for index, element := range elements {
+			channel, ok := element.(*object.Channel)
+			if !ok {
+				return newError("not a channel: %s", element.Type())
 			}
+			channels[index] = channel
+		}
 
-			return &object.Array{Elements: newElements}
-		},
-	}
-	builtins["reduce"] = &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 3 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+		index, value, ok := object.Select(channels)
+		if !ok {
+			value = NULL
+		}
+		return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(index)}, value}}
+	})
+
+	// string
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "str",
+		Parameters: []object.BuiltinParameter{param("value", anyType)},
+		Doc:        "convert value into a string",
+	}, func(args ...object.Object) object.Object {
+		if str, ok := args[0].(*object.String); ok {
+			return str
+		}
+		return &object.String{Value: args[0].Inspect()}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "split",
+		Parameters: []object.BuiltinParameter{param("s", stringType), optionalParam("separator", stringType)},
+		Doc:        "split s around separator, or around whitespaces if separator is omitted",
+	}, func(args ...object.Object) object.Object {
+		var parts []string
+		if len(args) == 1 {
+			parts = strings.Fields(args[0].(*object.String).Value)
+		} else {
+			parts = strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+		}
+		return nativeStringsToArray(parts)
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "join",
+		Parameters: []object.BuiltinParameter{param("arr", arrayType), optionalParam("separator", stringType)},
+		Doc:        "concatenate the elements of arr with separator between them",
+	}, func(args ...object.Object) object.Object {
+		separator := ""
+		if len(args) == 2 {
+			separator = args[1].(*object.String).Value
+		}
+
+		elements := args[0].(*object.Array).Elements
+		parts := make([]string, len(elements))
+		for index, element := range elements {
+			parts[index] = element.Inspect()
+		}
+		return &object.String{Value: strings.Join(parts, separator)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "trim",
+		Parameters: []object.BuiltinParameter{param("s", stringType), optionalParam("cutset", stringType)},
+		Doc:        "remove leading and trailing characters in cutset, or whitespaces, from s",
+	}, func(args ...object.Object) object.Object {
+		value := args[0].(*object.String).Value
+		if len(args) == 1 {
+			return &object.String{Value: strings.TrimSpace(value)}
+		}
+		return &object.String{Value: strings.Trim(value, args[1].(*object.String).Value)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "upper",
+		Parameters: []object.BuiltinParameter{param("s", stringType)},
+		Doc:        "return s with all letters mapped to upper case",
+	}, func(args ...object.Object) object.Object {
+		return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "lower",
+		Parameters: []object.BuiltinParameter{param("s", stringType)},
+		Doc:        "return s with all letters mapped to lower case",
+	}, func(args ...object.Object) object.Object {
+		return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "replace",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("old", stringType), param("new", stringType)},
+		Doc:        "replace all occurrences of old in s with new",
+	}, func(args ...object.Object) object.Object {
+		value := args[0].(*object.String).Value
+		old := args[1].(*object.String).Value
+		replacement := args[2].(*object.String).Value
+		return &object.String{Value: strings.ReplaceAll(value, old, replacement)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "contains",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("substr", stringType)},
+		Doc:        "return true if substr is within s",
+	}, func(args ...object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "startsWith",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("prefix", stringType)},
+		Doc:        "return true if s begins with prefix",
+	}, func(args ...object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "endsWith",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("suffix", stringType)},
+		Doc:        "return true if s ends with suffix",
+	}, func(args ...object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "indexOf",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("substr", stringType)},
+		Doc:        "return the character index of the first substr in s, or -1",
+	}, func(args ...object.Object) object.Object {
+		value := args[0].(*object.String).Value
+		index := strings.Index(value, args[1].(*object.String).Value)
+		if index < 0 {
+			return &object.Integer{Value: -1}
+		}
+		// convert byte offset into character offset
+		return &object.Integer{Value: int64(utf8.RuneCountInString(value[:index]))}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "substr",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("start", integerType), optionalParam("length", integerType)},
+		Doc:        "return length characters of s from start; negative start counts from the end",
+	}, func(args ...object.Object) object.Object {
+		runes := []rune(args[0].(*object.String).Value)
+		length := int64(len(runes))
+		start := clampIndex(args[1].(*object.Integer).Value, length)
+		end := length
+		if len(args) == 3 {
+			count := args[2].(*object.Integer).Value
+			if count < 0 {
+				count = 0
+			}
+			// compared before adding, which could overflow
+			if count < end-start {
+				end = start + count
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to first must be ARRAY, got %s", args[0].Type())
+		}
+		return &object.String{Value: string(runes[start:end])}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "repeat",
+		Parameters: []object.BuiltinParameter{param("s", stringType), param("count", integerType)},
+		Doc:        "return count copies of s",
+	}, func(args ...object.Object) object.Object {
+		count := args[1].(*object.Integer).Value
+		if count < 0 {
+			return newError("repeat count must not be negative, got %d", count)
+		}
+		s := args[0].(*object.String).Value
+		if count > 0 && int64(len(s)) > maxStringLength/count {
+			return newError("repeat result is too long, limit is %d bytes", maxStringLength)
+		}
+		return &object.String{Value: strings.Repeat(s, int(count))}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "chars",
+		Parameters: []object.BuiltinParameter{param("s", stringType)},
+		Doc:        "return the characters of s as an array of strings",
+	}, func(args ...object.Object) object.Object {
+		runes := []rune(args[0].(*object.String).Value)
+		elements := make([]object.Object, len(runes))
+		for index, r := range runes {
+			elements[index] = &object.String{Value: string(r)}
+		}
+		return &object.Array{Elements: elements}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "format",
+		Parameters: []object.BuiltinParameter{param("template", stringType), optionalParam("values", anyType)},
+		Variadic:   true,
+		Doc:        "format values according to template, using the verbs of go's fmt package",
+	}, func(args ...object.Object) object.Object {
+		values := make([]interface{}, len(args)-1)
+		for index, arg := range args[1:] {
+			values[index] = objectToNative(arg)
+		}
+		return &object.String{Value: fmt.Sprintf(args[0].(*object.String).Value, values...)}
+	})
+	registerBuiltin(object.BuiltinSignature{
+		Name:       "sprintf",
+		Parameters: []object.BuiltinParameter{param("template", stringType), optionalParam("values", anyType)},
+		Variadic:   true,
+		Doc:        "alias of format",
+	}, builtins["format"].Fn)
+
+	// method
+	registerEnvironmentBuiltin(object.BuiltinSignature{
+		Name:       "method",
+		Parameters: []object.BuiltinParameter{param("type", []object.ObjectType{object.OBJECT_TYPE_OBJ, object.STRUCT_OBJ}), param("name", stringType), param("fn", callableType)},
+		Doc:        "attach fn to type as method name; fn takes the receiver as first argument",
+	}, func(env *object.Environment) object.BuiltinFunction {
+		return func(args ...object.Object) object.Object {
+			name := args[1].(*object.String).Value
+			switch arg := args[0].(type) {
+			case *object.StructType:
+				arg.Methods.Set(name, args[2])
+			case *object.ObjectTypeObject:
+				if arg.Struct != nil {
+					arg.Struct.Methods.Set(name, args[2])
+				} else {
+					env.Runtime().Methods(arg.Value).Set(name, args[2])
+				}
 			}
-			if args[2].Type() != object.FUNCTION_OBJ {
-				return newError("argument to second must be FUNCTION, got %s", args[1].Type())
+			return NULL
+		}
+	})
+	registerEnvironmentBuiltin(object.BuiltinSignature{
+		Name:       "methods",
+		Parameters: []object.BuiltinParameter{param("value", anyType)},
+		Doc:        "return the names of methods callable on value",
+	}, func(env *object.Environment) object.BuiltinFunction {
+		return func(args ...object.Object) object.Object {
+			if instance, ok := args[0].(*object.Struct); ok {
+				return nativeStringsToArray(instance.Definition.Methods.Names())
 			}
 
-			accumulated := args[1]
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length == 0 {
-				return accumulated
+			names := object.MethodNames(args[0].Type())
+			for _, name := range env.Runtime().Methods(args[0].Type()).Names() {
+				if _, ok := object.LookupMethod(args[0].Type(), name); !ok {
+					names = append(names, name)
+				}
 			}
+			sort.Strings(names)
+			return nativeStringsToArray(names)
+		}
+	})
 
-			fn := args[2].(*object.Function)
+	registerBuiltinMethods()
+	// len accepts any value to report unsupported ones itself
+	for _, objectType := range []object.ObjectType{object.STRING_OBJ, object.ARRAY_OBJ, object.HASH_OBJ} {
+		object.RegisterMethod(objectType, "len", builtins["len"])
+	}
+}
 
-			for _, element := range arr.Elements {
-				accumulated = applyFunction(fn, []object.Object{accumulated, element})
-			}
+// attach builtins as methods of the types accepted by their first parameter,
+// so that "push(arr, 1)" can be called as "arr.push(1)"
+//
+// builtins whose only parameter is optional, like help(), and builtins
+// needing the environment of their caller are left out
+func registerBuiltinMethods() {
+	for name, builtin := range builtins {
+		signature := builtin.Signature
+		if len(signature.Parameters) == 0 || (signature.Parameters[0].Optional && !signature.Variadic) {
+			continue
+		}
+		if _, ok := environmentBuiltins[name]; ok {
+			continue
+		}
+		parameters := signature.Parameters
+		for _, objectType := range parameters[0].Types {
+			object.RegisterMethod(objectType, name, builtin)
+		}
+	}
+}
+
+// describe all built-in functions, sorted by name
+func builtinsHelp() string {
+	var out bytes.Buffer
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		signature := builtins[name].Signature
+		out.WriteString(signature.String())
+		out.WriteString("\n\t")
+		out.WriteString(signature.Doc)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// return a function releasing iterator, which does nothing if it holds nothing
+func closeIterator(iterator object.Iterator) func() {
+	if closer, ok := iterator.(object.Closer); ok {
+		return closer.Close
+	}
+	return func() {}
+}
+
+// clamp index into [0, length], counting negative indices from the end
+func clampIndex(index, length int64) int64 {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// convert a go string slice into an array of strings
+func nativeStringsToArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for index, value := range values {
+		elements[index] = &object.String{Value: value}
+	}
+	return &object.Array{Elements: elements}
+}
 
-			return accumulated
-		},
+// convert object into a go value for use with fmt verbs
+//
+// objects without a natural go counterpart are rendered with Inspect
+func objectToNative(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInteger:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return obj.Inspect()
 	}
 }
@@ -1,24 +1,60 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"unicode/utf8"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// limit of shift count, to keep results of left shift in a sane size
+const maxShiftCount = 1 << 16
+
+// limit of bytes in strings built by repetition, to fail before running out of memory
+const maxStringLength = 1 << 30
+
+// limit of elements in arrays built by range
+const maxRangeLength = 1 << 26
+
+// limit of values buffered by a channel, whose buffer is allocated upfront
+const maxChannelSize = 1 << 20
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return locateError(evalNode(node, env), node, env)
+}
+
+// errors are located at the innermost node they passed through
+func locateError(result object.Object, node ast.Node, env *object.Environment) object.Object {
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		tok := ast.NodeToken(node)
+		err.Line, err.Column = tok.Line, tok.Column
+		err.Source = env.Source()
+	}
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	//
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInteger{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.Identifier:
@@ -35,11 +71,34 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "??" && left != NULL {
+			return left
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		if node.Operator == "??" {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right, env)
+	case *ast.ConditionalExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return Eval(node.Consequence, env)
+		}
+		return Eval(node.Alternative, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -51,19 +110,29 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
-	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		if node.Pattern == nil {
+			env.Set(node.Name.Value, val)
+		} else if err := bindPattern(node.Pattern, val, env); err != nil {
+			return err
 		}
-
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+	case *ast.StructStatement:
+		if object.IsBuiltinType(node.Name.Value) {
+			return newError("struct %s conflicts with built-in type", node.Name.Value)
 		}
-
-		return applyFunction(function, args)
+		fields := make([]string, len(node.Fields))
+		for index, field := range node.Fields {
+			fields[index] = field.Value
+		}
+		env.Set(node.Name.Value, &object.StructType{Name: node.Name.Value, Fields: fields})
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+	case *ast.CallExpression:
+		result, _ := evalChainStep(node, env)
+		return result
+	case *ast.PipeExpression:
+		return evalPipeExpression(node, env)
+	case *ast.SpreadExpression:
+		return newError("spread operator not allowed here")
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -72,16 +141,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Array{Elements: elements}
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
-	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		index := Eval(node.Index, env)
-		if isError(index) {
-			return index
-		}
-		return evalIndexExpression(left, index)
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		result, _ := evalChainStep(node.(ast.Expression), env)
+		return result
 	//
 	case *ast.BlockStatement:
 		return evalBlockStatemen(node.Statements, env)
@@ -89,9 +151,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIfExpression(node, env)
 	case *ast.FunctionLiteral:
 		return &object.Function{
+			Name:       node.Name,
+			Generator:  node.Generator,
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Env:        env,
 			Body:       node.Body,
+			Source:     env.Source(),
 		}
 	//
 	case *ast.Program:
@@ -112,6 +179,8 @@ func evalProgram(statements []ast.Statement, env *object.Environment) object.Obj
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of loop", result.Inspect())
 		}
 	}
 	return result
@@ -124,7 +193,7 @@ func evalBlockStatemen(statements []ast.Statement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -132,6 +201,115 @@ func evalBlockStatemen(statements []ast.Statement, env *object.Environment) obje
 	return result
 }
 
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	iterator, err := iteratorOf(iterable)
+	if err != nil {
+		return err
+	}
+	if closer, ok := iterator.(object.Closer); ok {
+		defer closer.Close()
+	}
+
+	for {
+		item, ok := iterator.Next()
+		if !ok {
+			break
+		}
+		if isError(item) {
+			return item
+		}
+
+		// each iteration gets its own scope so closures capture its variables
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if node.Key != nil {
+			pair, ok := item.(*object.Array)
+			if !ok || len(pair.Elements) != 2 {
+				return newError("cannot unpack %s into 2 loop variables", item.Inspect())
+			}
+			loopEnv.Set(node.Key.Value, pair.Elements[0])
+			loopEnv.Set(node.Value.Value, pair.Elements[1])
+		} else {
+			loopEnv.Set(node.Value.Value, item)
+		}
+
+		result := Eval(node.Body, loopEnv)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			case object.BREAK_OBJ:
+				return NULL
+			}
+		}
+	}
+	return NULL
+}
+
+// hand value over to the consumer of the innermost generator
+//
+// if the consumer stops iterating, the generator body is unwound like return
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.Object {
+	var value object.Object = NULL
+	if node.Value != nil {
+		value = Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+	}
+
+	yield, ok := env.Yield()
+	if !ok {
+		return newError("yield outside of generator")
+	}
+	if !yield(value) {
+		return &object.ReturnValue{Value: NULL}
+	}
+	return nil
+}
+
+// resolve field of struct, or method of the type of obj bound to obj
+func evalMemberExpression(obj object.Object, name string, env *object.Environment) object.Object {
+	if instance, ok := obj.(*object.Struct); ok {
+		if value, ok := instance.Get(name); ok {
+			return value
+		}
+	}
+	if method, ok := lookupMethod(obj, name, env); ok {
+		return &object.BoundMethod{Receiver: obj, Name: name, Method: method}
+	}
+	return newError("%s has no field or method %s", obj.Type(), name)
+}
+
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	obj := Eval(node.Target.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	name := node.Target.Member.Value
+	instance, ok := obj.(*object.Struct)
+	if !ok || !instance.Set(name, value) {
+		return newError("%s has no field %s", obj.Type(), name)
+	}
+	return nil
+}
+
+func iteratorOf(obj object.Object) (object.Iterator, *object.Error) {
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, newError("not iterable: %s", obj.Type())
+	}
+	return iterable.Iterator(), nil
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -154,6 +332,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -176,17 +356,38 @@ func evalMinusOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	if integer, ok := right.(*object.Integer); ok && integer.Value != math.MinInt64 {
+		return &object.Integer{Value: -integer.Value}
+	}
+	value, _ := object.ToBigInt(right)
+	return object.NewInteger(new(big.Int).Neg(value))
+}
+
+func evalBitwiseNotOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	if integer, ok := right.(*object.Integer); ok {
+		return &object.Integer{Value: ^integer.Value}
+	}
+	value, _ := object.ToBigInt(right)
+	return object.NewInteger(new(big.Int).Not(value))
 }
 
 // infix expression
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func evalInfixExpression(operator string, left object.Object, right object.Object, env *object.Environment) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	}
+
+	if result, ok := evalOverloadedInfixExpression(operator, left, right, env); ok {
+		return result
+	}
+
+	switch {
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -198,19 +399,114 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
+// call method named after operator, like method(Vec, "+", fn(a, b) { ... }),
+// of the type of left or else of right
+//
+// "!=" falls back to negated "==" and ">" to "<" with swapped operands;
+// results of comparisons are converted into booleans
+func evalOverloadedInfixExpression(operator string, left, right object.Object, env *object.Environment) (object.Object, bool) {
+	negate := false
+	fn, ok := lookupOperatorMethod(operator, left, right, env)
+	if !ok && operator == "!=" {
+		fn, ok = lookupOperatorMethod("==", left, right, env)
+		negate = true
+	}
+	if !ok && operator == ">" {
+		fn, ok = lookupOperatorMethod("<", right, left, env)
+		left, right = right, left
+	}
+	if !ok {
+		return nil, false
+	}
+
+	result := applyFunction(fn, []object.Object{left, right})
+	switch {
+	case isError(result):
+		return result, true
+	case operator == "==" || operator == "!=" || operator == "<" || operator == ">":
+		return nativeBoolToBooleanObject(isTruthy(result) != negate), true
+	default:
+		return result, true
+	}
+}
+
+func lookupOperatorMethod(operator string, left, right object.Object, env *object.Environment) (object.Object, bool) {
+	if fn, ok := lookupMethod(left, operator, env); ok {
+		return fn, true
+	}
+	return lookupMethod(right, operator, env)
+}
+
+// find method name of obj. methods of structs are attached to their
+// declaration, while methods of other types attached by the program are
+// looked up before those of the host
+func lookupMethod(obj object.Object, name string, env *object.Environment) (object.Object, bool) {
+	if instance, ok := obj.(*object.Struct); ok {
+		return instance.Definition.Methods.Get(name)
+	}
+	if fn, ok := env.Runtime().Methods(obj.Type()).Get(name); ok {
+		return fn, true
+	}
+	return object.LookupMethod(obj.Type(), name)
+}
+
+// evaluate infix expression of integers, promoting results which overflow
+// int64 into big integers
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftInteger, leftOk := left.(*object.Integer)
+	rightInteger, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+	leftVal := leftInteger.Value
+	rightVal := rightInteger.Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		sum := leftVal + rightVal
+		if (rightVal > 0 && sum < leftVal) || (rightVal < 0 && sum > leftVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: sum}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		difference := leftVal - rightVal
+		if (rightVal < 0 && difference < leftVal) || (rightVal > 0 && difference > leftVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: difference}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: product}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if rightVal >= 63 || (leftVal<<rightVal)>>rightVal != leftVal {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal << rightVal}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -224,6 +520,62 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+func evalBigIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal, _ := object.ToBigInt(left)
+	rightVal, _ := object.ToBigInt(right)
+
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// truncated like division of int64
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "&":
+		return object.NewInteger(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return object.NewInteger(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return object.NewInteger(new(big.Int).Xor(leftVal, rightVal))
+	case "<<":
+		if rightVal.Sign() < 0 {
+			return newError("negative shift count: %s", rightVal)
+		}
+		if !rightVal.IsUint64() || rightVal.Uint64() > maxShiftCount {
+			return newError("shift count too large: %s", rightVal)
+		}
+		return object.NewInteger(new(big.Int).Lsh(leftVal, uint(rightVal.Uint64())))
+	case ">>":
+		if rightVal.Sign() < 0 {
+			return newError("negative shift count: %s", rightVal)
+		}
+		if !rightVal.IsUint64() {
+			// every bit is shifted out
+			if leftVal.Sign() < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: 0}
+		}
+		return object.NewInteger(new(big.Int).Rsh(leftVal, uint(rightVal.Uint64())))
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -240,11 +592,124 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for _, part := range node.Parts {
+		evaluated := Eval(part, env)
+		if isError(evaluated) {
+			return evaluated
+		}
+		out.WriteString(evaluated.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+// bind value to identifiers in pattern, destructuring arrays and hashes
+func bindPattern(pattern ast.Expression, value object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Set(pattern.Value, value)
+		return nil
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, value, env)
+	default:
+		return newError("invalid destructuring target: %s", pattern.String())
+	}
+}
+
+// bind value or default value of element if value is nil
+func bindPatternElement(element *ast.PatternElement, value object.Object, env *object.Environment) *object.Error {
+	if value == nil {
+		value = Eval(element.Default, env)
+		if isError(value) {
+			return value.(*object.Error)
+		}
+	}
+	return bindPattern(element.Target, value, env)
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) *object.Error {
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s as ARRAY_OBJ", value.Type())
+	}
+
+	required := 0
+	for index, element := range pattern.Elements {
+		if element.Default == nil {
+			required = index + 1
+		}
+	}
+	length := len(arr.Elements)
+	if length < required || (pattern.Rest == nil && length > len(pattern.Elements)) {
+		max := len(pattern.Elements)
+		if pattern.Rest != nil {
+			max = -1
+		}
+		return newError("wrong number of elements to destructure. got=%d, want=%s", length, object.FormatArity(required, max))
+	}
+
+	for index, element := range pattern.Elements {
+		var elementValue object.Object
+		if index < length {
+			elementValue = arr.Elements[index]
+		}
+		if err := bindPatternElement(element, elementValue, env); err != nil {
+			return err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := []object.Object{}
+		if length > len(pattern.Elements) {
+			rest = append(rest, arr.Elements[len(pattern.Elements):]...)
+		}
+		env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+	}
+	return nil
+}
+
+func bindHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) *object.Error {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return newError("cannot destructure %s as HASH_OBJ", value.Type())
+	}
+
+	used := make(map[object.HashKey]bool)
+	for _, pair := range pattern.Pairs {
+		key := (&object.String{Value: pair.Key.Value}).HashKey()
+		used[key] = true
+
+		var pairValue object.Object
+		if found, ok := hash.Pairs[key]; ok {
+			pairValue = found.Value
+		} else if pair.Value.Default == nil {
+			return newError("key not found to destructure: %s", pair.Key.Value)
+		}
+		if err := bindPatternElement(pair.Value, pairValue, env); err != nil {
+			return err
+		}
+	}
+
+	if pattern.Rest != nil {
+		pairs := make(map[object.HashKey]object.HashPair)
+		for key, pair := range hash.Pairs {
+			if !used[key] {
+				pairs[key] = pair
+			}
+		}
+		env.Set(pattern.Rest.Value, &object.Hash{Pairs: pairs})
+	}
+	return nil
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := lookupBuiltin(node.Value, env); ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
@@ -253,6 +718,19 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				return []object.Object{newError("spread operator not supported: %s", evaluated.Type())}
+			}
+			result = append(result, arr.Elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -262,28 +740,218 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func evalIndexExpression(left, index object.Object, env *object.Environment) object.Object {
 	switch {
+	case (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ) && isBigInteger(index):
+		// never in range
+		if env.Runtime().StrictIndexing {
+			return newError("index out of range: %s with length %s", index.Inspect(), builtins["len"].Fn(left).Inspect())
+		}
+		return NULL
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return evalArrayIndexExpression(left, index, env.Runtime().StrictIndexing)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, env.Runtime().StrictIndexing)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
+		if fn, ok := lookupMethod(left, "[]", env); ok {
+			return applyFunction(fn, []object.Object{left, index})
+		}
 		return newError("index operator not supported:%s", left.Type())
 	}
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
+func evalArrayIndexExpression(array, index object.Object, strict bool) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
-		return NULL
+	length := int64(len(arrayObject.Elements))
+	idx, ok := resolveIndex(index.(*object.Integer).Value, length)
+	if !ok {
+		return indexOutOfRange(index.(*object.Integer).Value, length, strict)
 	}
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object, strict bool) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	length := int64(len(runes))
+	idx, ok := resolveIndex(index.(*object.Integer).Value, length)
+	if !ok {
+		return indexOutOfRange(index.(*object.Integer).Value, length, strict)
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+// evaluate a step of a postfix chain like "a?.b[0].c()". once an optional
+// step finds null, the rest of the chain is skipped, so that the whole chain
+// yields null and true is returned
+func evalChainStep(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		function, skipped := evalChainOperand(node.Function, env)
+		if skipped {
+			return NULL, true
+		}
+		if isError(function) {
+			return function, false
+		}
+		if node.Optional && function == NULL {
+			return NULL, true
+		}
+		// "x?.method()" yields null without calling when x is null
+		if member, ok := node.Function.(*ast.MemberExpression); ok && member.Optional && function == NULL {
+			return NULL, true
+		}
+
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0], false
+		}
+
+		return applyFunction(function, args), false
+	case *ast.IndexExpression:
+		left, skipped := evalChainOperand(node.Left, env)
+		if skipped {
+			return NULL, true
+		}
+		if isError(left) {
+			return left, false
+		}
+		if node.Optional && left == NULL {
+			return NULL, true
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index, false
+		}
+		return evalIndexExpression(left, index, env), false
+	case *ast.SliceExpression:
+		left, skipped := evalChainOperand(node.Left, env)
+		if skipped {
+			return NULL, true
+		}
+		if isError(left) {
+			return left, false
+		}
+		if node.Optional && left == NULL {
+			return NULL, true
+		}
+		return evalSliceExpression(node, left, env), false
+	case *ast.MemberExpression:
+		obj, skipped := evalChainOperand(node.Object, env)
+		if skipped {
+			return NULL, true
+		}
+		if isError(obj) {
+			return obj, false
+		}
+		if node.Optional && obj == NULL {
+			return NULL, true
+		}
+		return evalMemberExpression(obj, node.Member.Value, env), false
+	default:
+		return Eval(node, env), false
+	}
+}
+
+// evaluate the operand of a postfix step, reporting whether an optional step
+// before it skipped the rest of the chain
+func evalChainOperand(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	result, skipped := evalChainStep(node, env)
+	return locateError(result, node, env), skipped
+}
+
+func evalSliceExpression(node *ast.SliceExpression, left object.Object, env *object.Environment) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := evalSliceBound(node.Start, env, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := evalSliceBound(node.End, env, length, length)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		if env.Runtime().StrictIndexing {
+			return newError("slice bounds out of range: [%d:%d]", start, end)
+		}
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		newElements := make([]object.Object, end-start)
+		copy(newElements, left.Elements[start:end])
+		return &object.Array{Elements: newElements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[start:end])}
+	}
+}
+
+// evaluate a bound of slice expression into [0, length]
+//
+// omitted bound yields defaultValue
+func evalSliceBound(node ast.Expression, env *object.Environment, defaultValue, length int64) (int64, *object.Error) {
+	if node == nil {
+		return defaultValue, nil
+	}
+
+	bound := Eval(node, env)
+	if isError(bound) {
+		return 0, bound.(*object.Error)
+	}
+	if isBigInteger(bound) {
+		if env.Runtime().StrictIndexing {
+			return 0, newError("slice bound out of range: %s with length %d", bound.Inspect(), length)
+		}
+		if bound.(*object.BigInteger).Value.Sign() < 0 {
+			return 0, nil
+		}
+		return length, nil
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError("slice bound must be INTEGER, got %s", bound.Type())
+	}
+
+	if env.Runtime().StrictIndexing {
+		idx := integer.Value
+		if idx < 0 {
+			idx += length
+		}
+		if idx < 0 || idx > length {
+			return 0, newError("slice bound out of range: %d with length %d", integer.Value, length)
+		}
+		return idx, nil
+	}
+	return clampIndex(integer.Value, length), nil
+}
+
+// resolve negative index from the end and check it is in range
+func resolveIndex(index, length int64) (int64, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, 0 <= index && index < length
+}
+
+func indexOutOfRange(index, length int64, strict bool) object.Object {
+	if strict {
+		return newError("index out of range: %d with length %d", index, length)
+	}
+	return NULL
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
@@ -325,25 +993,125 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 	return &object.Hash{Pairs: pairs}
 }
 
+// call right side of pipe with left side as the first argument
+//
+// "x |> f(y)" is evaluated as "f(x, y)" and "x |> f" as "f(x)"
+func evalPipeExpression(node *ast.PipeExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	call, ok := node.Right.(*ast.CallExpression)
+	if !ok {
+		function := Eval(node.Right, env)
+		if isError(function) {
+			return function
+		}
+		return applyFunction(function, []object.Object{left})
+	}
+
+	function := Eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return applyFunction(function, append([]object.Object{left}, args...))
+}
+
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return object.NewGenerator(func(yield func(object.Object) bool) object.Object {
+				extendedEnv.SetYield(yield)
+				return evalFunctionBody(fn, extendedEnv)
+			})
+		}
+		return evalFunctionBody(fn, extendedEnv)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Call(args...)
+	case *object.StructType:
+		return newStructInstance(fn, args)
+	case *object.BoundMethod:
+		return applyFunction(fn.Method, append([]object.Object{fn.Receiver}, args...))
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func evalFunctionBody(fn *object.Function, env *object.Environment) object.Object {
+	evaluated := Eval(fn.Body, env)
+	if evaluated == BREAK || evaluated == CONTINUE {
+		return newError("%s outside of loop", evaluated.Inspect())
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// bind arguments to parameters of fn in a new environment
+//
+// parameters without an argument take their default values
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	min, max := fn.Arity()
+	if max >= 0 && len(args) > max {
+		return nil, functionArityError(fn, len(args), min, max)
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
+	env.SetSource(fn.Source)
 	for paramIndex, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIndex])
+		if paramIndex < len(args) {
+			env.Set(param.Value, args[paramIndex])
+			continue
+		}
+		if defaultValue, ok := fn.Defaults[param.Value]; ok {
+			value := Eval(defaultValue, env)
+			if isError(value) {
+				return nil, value.(*object.Error)
+			}
+			env.Set(param.Value, value)
+			continue
+		}
+		return nil, functionArityError(fn, len(args), min, max)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// construct instance of st from values of its fields in declaration order
+func newStructInstance(st *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(st.Fields) {
+		return newError("wrong number of arguments to %s. got=%d, want=%d", st.Name, len(args), len(st.Fields))
+	}
+
+	values := make([]object.Object, len(st.Fields))
+	copy(values, args)
+	return object.NewStruct(st, values)
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
 	}
-	return env
+	return fn.Name
+}
+
+func functionArityError(fn *object.Function, got, min, max int) *object.Error {
+	return newError("wrong number of arguments to %s. got=%d, want=%s", functionName(fn), got, object.FormatArity(min, max))
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -361,6 +1129,11 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+func isBigInteger(obj object.Object) bool {
+	_, ok := obj.(*object.BigInteger)
+	return ok
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
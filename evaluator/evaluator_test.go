@@ -32,6 +32,81 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-1 * (-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 - 123456789012345678901234567889", "1"},
+		{"type(123456789012345678901234567890 - 123456789012345678901234567889)", "<type INTEGER>"},
+		{"-100000000000000000000 / 7", "-14285714285714285714"},
+		{"let f = fn(n) { n < 2 ? 1 : n * f(n - 1) }; f(21)", "51090942171709440000"},
+		{"let f = fn(n) { n < 2 ? 1 : n * f(n - 1) }; f(30)", "265252859812191058636308480000000"},
+		{"100000000000000000000 == 100000000000000000000", "true"},
+		{"100000000000000000000 > 9223372036854775807", "true"},
+		{"-100000000000000000000 < 1", "true"},
+		{"sort([100000000000000000000, 1, -100000000000000000000])", "[-100000000000000000000, 1, 100000000000000000000]"},
+		{`{9223372036854775807 + 1 - 1: "a"}[9223372036854775807]`, "a"},
+		{`{100000000000000000000: "a"}[10000000000000000000 * 10]`, "a"},
+		{`format("%d", 100000000000000000000)`, "100000000000000000000"},
+		{"[1, 2][100000000000000000000]", "null"},
+		{"[1, 2, 3][-100000000000000000000:100000000000000000000]", "[1, 2, 3]"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"100000000000000000000 / 0", "ERROR: division by zero"},
+		{"range(100000000000000000000)", "ERROR: first argument to range out of range: 100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0b1100 & 0b1010", "8"},
+		{"0b1100 | 0b1010", "14"},
+		{"0b1100 ^ 0b1010", "6"},
+		{"~0", "-1"},
+		{"~0xff", "-256"},
+		{"1 << 10", "1024"},
+		{"1_024 >> 3", "128"},
+		{"-16 >> 2", "-4"},
+		{"1 >> 100", "0"},
+		{"-1 >> 100", "-1"},
+		{"0x10 + 0o10 + 0b10 + 1_0", "36"},
+		{"map([1, 2], x => x & 1 == 0 ? \"even\" : \"odd\")", "[odd, even]"},
+		{"1 << 64", "18446744073709551616"},
+		{"3 << 62", "13835058055282163712"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"(1 << 100) >> 99", "2"},
+		{"(1 << 100) & ((1 << 100) | 5)", "1267650600228229401496703205376"},
+		{"(1 << 100) ^ (1 << 100)", "0"},
+		{"~(1 << 64)", "-18446744073709551617"},
+		{"-(1 << 100) >> (1 << 70)", "-1"},
+		{"1 << -1", "ERROR: negative shift count: -1"},
+		{"1 << (1 << 20)", "ERROR: shift count too large: 1048576"},
+		{"true & false", "ERROR: unknown operator: BOOLEAN & BOOLEAN"},
+		{"~true", "ERROR: unknown operator: ~BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
 func TestEvalStringExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -47,6 +122,25 @@ func TestEvalStringExpression(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "monkey"; "Hello ${name}!"`, "Hello monkey!"},
+		{`let items = [1, 2]; "you have ${len(items)} items: ${items}"`, "you have 2 items: [1, 2]"},
+		{`"${1 + 2}${true}"`, "3true"},
+		{`"${upper("a")} and ${ {"k": "v"}["k"] }"`, "A and v"},
+		{`"${"nested ${1}"}"`, "nested 1"},
+		{`"price: \${1}"`, "price: ${1}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testStringObject(t, tt.expected, evaluated)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -168,6 +262,7 @@ func TestErrorHandling(t *testing.T) {
 			return 1;
 		}`, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
+		{`"${foobar}"`, "identifier not found: foobar"},
 		{`{"name": "Monkey"}[fn(x) {x}]`, "unhashable as hash key: FUNCTION"},
 	}
 
@@ -179,6 +274,30 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"5 + true;", 1, 3},
+		{"let a = 1;\nlet b = a + foobar;", 2, 13},
+		{"let f = fn(x) {\n  x / 0\n};\nf(1)", 2, 5},
+		{"len(1, 2)", 1, 4},
+		{"[1, 2].nothing", 1, 7},
+		{`puts("x ${1 + true} y")`, 1, 13},
+		{"break", 0, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		require.True(t, ok, "no error object returned for %s", tt.input)
+		require.Equal(t, tt.expectedLine, errObj.Line, "wrong line for %s", tt.input)
+		require.Equal(t, tt.expectedColumn, errObj.Column, "wrong column for %s", tt.input)
+	}
+}
+
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -196,6 +315,49 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let 변수 = 5; 변수 * 2", "10"},
+		{`let café = "crème"; [len(café), café[2], café[-1]]`, "[5, è, e]"},
+		{"let π2 = fn(r) { r * 3 }; π2(2)", "6"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestDestructuringLetStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b", "3"},
+		{"let [a, ...rest] = [1, 2, 3]; rest", "[2, 3]"},
+		{"let [a, b = 10] = [1]; b", "10"},
+		{"let [a, [b, c]] = [1, [2, 3]]; c", "3"},
+		{`let {name, age: years} = {"name": "monkey", "age": 3}; "${name} ${years}"`, "monkey 3"},
+		{`let {name, role = "guest"} = {"name": "monkey"}; role`, "guest"},
+		{`let {pos: [x, y]} = {"pos": [1, 2]}; x * y`, "2"},
+		{`let {a, ...others} = {"a": 1, "b": 2, "c": 3}; keys(others)`, "[b, c]"},
+		{"let [a, b] = [1];", "ERROR: wrong number of elements to destructure. got=1, want=2"},
+		{"let [a] = [1, 2];", "ERROR: wrong number of elements to destructure. got=2, want=1"},
+		{"let [a, ...rest] = [];", "ERROR: wrong number of elements to destructure. got=0, want=>=1"},
+		{"let [a] = 1;", "ERROR: cannot destructure INTEGER as ARRAY_OBJ"},
+		{"let {a} = [1];", "ERROR: cannot destructure ARRAY_OBJ as HASH_OBJ"},
+		{`let {a} = {"b": 1};`, "ERROR: key not found to destructure: a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) {x + 2;};"
 	evaluated := testEval(input)
@@ -242,6 +404,343 @@ func TestClosure(t *testing.T) {
 	testIntegerObject(t, 4, evaluated)
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(1)", "11"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2)", "3"},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3)", "9"},
+		{"let f = fn(first, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(first, ...rest) { rest }; f(1)", "[]"},
+		{"let add = fn(x, y) { x + y }; add(...[1, 2])", "3"},
+		{"let f = fn(...args) { args }; f(0, ...[1, 2], 3)", "[0, 1, 2, 3]"},
+		{"[0, ...[1, 2]]", "[0, 1, 2]"},
+		{"let add = fn(x, y) { x + y }; add(1)", "ERROR: wrong number of arguments to add. got=1, want=2"},
+		{"let add = fn(x, y) { x + y }; add(1, 2, 3)", "ERROR: wrong number of arguments to add. got=3, want=2"},
+		{"let f = fn(x, y = 1) { x }; f()", "ERROR: wrong number of arguments to f. got=0, want=1 or 2"},
+		{"let f = fn(x, ...rest) { x }; f()", "ERROR: wrong number of arguments to f. got=0, want=>=1"},
+		{"fn(x) { x }()", "ERROR: wrong number of arguments to anonymous function. got=0, want=1"},
+		{"let f = fn(x) { x }; f(...1)", "ERROR: spread operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestArrowFunctionAndPipeline(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], x => x * 2)", "[2, 4, 6]"},
+		{"let add = (a, b) => a + b; add(1, 2)", "3"},
+		{"let f = (a, b = 10) => a + b; f(1)", "11"},
+		{"let f = () => { let x = 1; x + 1 }; f()", "2"},
+		{"let adder = x => y => x + y; adder(1)(2)", "3"},
+		{"[1, 2, 3] |> map(x => x * 2) |> reduce(0, (a, b) => a + b)", "12"},
+		{"let double = x => x * 2; 5 |> double", "10"},
+		{`"monkey" |> upper |> len`, "6"},
+		{"1 |> 2", "ERROR: not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestConditionalAndNullishOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true ? 1 : 2", "1"},
+		{"1 > 2 ? 1 : 2", "2"},
+		{"let x = 5; x > 3 ? x < 10 ? \"mid\" : \"high\" : \"low\"", "mid"},
+		{"true ? 1 : foobar", "1"},
+		{`{"a": 1}["b"] ?? 0`, "0"},
+		{`{"a": 1}["a"] ?? foobar`, "1"},
+		{"false ?? 1", "false"},
+		{`let h = {"a": {"b": 2}}; h["a"]?.["b"]`, "2"},
+		{`let h = {"a": {"b": 2}}; h["x"]?.["b"]`, "null"},
+		{`let h = {}; h["x"]?.["b"] ?? "default"`, "default"},
+		{`let h = {}; h["f"]?.(foobar)`, "null"},
+		{`let h = {"f": fn(x) { x * 2 }}; h["f"]?.(2)`, "4"},
+		{`let h = {}; h["s"]?.[1:]`, "null"},
+		{`let h = {}; h["x"]["b"]`, "ERROR: index operator not supported:NULL"},
+		{`let h = {}["x"]; h?.["a"]["b"]`, "null"},
+		{`let h = {}["x"]; h?.["a"].b(1)[2:]`, "null"},
+		{`let h = {}["x"]; h?.["a"][foobar]`, "null"},
+		{`let h = {"a": {}["x"]}; h?.["a"]["b"]`, "ERROR: index operator not supported:NULL"},
+		{`let h = {}; h["f"]?.(1).x`, "null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(arr) { for (x in arr) { if (x > 2) { return x; } } }; f([1, 2, 3, 4])", "3"},
+		{"let f = fn(arr) { for (x in arr) { if (x < 3) { continue; } return x; } }; f([1, 2, 3, 4])", "3"},
+		{`let f = fn() { for (x in [1, 2]) { break; return x; } "done" }; f()`, "done"},
+		{`let f = fn(h) { for (k, v in h) { if (v == 2) { return k; } } }; f({"a": 1, "b": 2})`, "b"},
+		{`let f = fn(h) { for (pair in h) { return pair; } }; f({"b": 2, "a": 1})`, "[a, 1]"},
+		{`let f = fn(s) { for (ch in s) { if (ch != "h") { return ch; } } }; f("hé")`, "é"},
+		{"let f = fn() { for (i in range(0, 10)) { if (i * i > 10) { return i; } } }; f()", "4"},
+		{"for (x in []) { x }", "null"},
+		{"for (x in [1]) { let y = x; } y", "ERROR: identifier not found: y"},
+		{"for (x in 1) { x }", "ERROR: not iterable: INTEGER"},
+		{"for (a, b in [1]) { a }", "ERROR: cannot unpack 1 into 2 loop variables"},
+		{"for (x in [1]) { x + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"break;", "ERROR: break outside of loop"},
+		{"let f = fn() { continue; }; for (x in [1]) { f() }", "ERROR: continue outside of loop"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let gen = fn() { yield 1; yield 2; }; collect(gen())", "[1, 2]"},
+		{"let gen = fn() { yield; }; collect(gen())", "[null]"},
+		{"let gen = fn(n) { for (i in range(n)) { yield i * 2; } }; collect(gen(3))", "[0, 2, 4]"},
+		{"let gen = fn() { yield 1; return 5; yield 2; }; collect(gen())", "[1]"},
+		{"let nat = fn() { for (i in iterate(x => x + 1, 0)) { yield i; } }; collect(take(nat(), 3))", "[0, 1, 2]"},
+		{"let gen = () => { yield 1; }; type(gen())", "<type ITERATOR>"},
+		{"let gen = fn() { yield 1; }; let g = gen(); collect(g); collect(g)", "[]"},
+		{`let gen = fn() { yield 1; yield 2; yield 3; };
+		let f = fn() { for (x in gen()) { if (x == 2) { return x; } } };
+		f()`, "2"},
+		{`let outer = fn() { let inner = fn() { yield 1; }; yield collect(inner()); }; collect(outer())`, "[[1]]"},
+		{"let gen = fn() { yield 1; yield 1 + true; }; collect(gen())", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let gen = fn() { yield 1; break; }; collect(gen())", "ERROR: break outside of loop"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }; Point(1, 2)", "Point{x: 1, y: 2}"},
+		{"struct Point { x, y }; Point", "struct Point { x, y }"},
+		{"struct Point { x, y }; Point(1, 2).y", "2"},
+		{"struct Empty {}; Empty()", "Empty{}"},
+		{"struct Point { x, y }; let p = Point(1, 2); p.x + p.y", "3"},
+		{"struct Point { x, y }; let p = Point(1, 2); p.x = 10; p", "Point{x: 10, y: 2}"},
+		{"struct Point { x, y }; let p = Point(1, 2); let move = fn(q) { q.x = q.x + 1 }; move(p); p.x", "2"},
+		{"struct Line { a, b }; struct Point { x, y }; let l = Line(Point(0, 0), Point(1, 2)); l.b.y", "2"},
+		{"struct Line { a, b }; struct Point { x, y }; let l = Line(Point(0, 0), Point(1, 2)); l.b.y = 5; l", "Line{a: Point{x: 0, y: 0}, b: Point{x: 1, y: 5}}"},
+		{"struct Point { x, y }; type(Point(1, 2))", "<type Point>"},
+		{"struct Point { x, y }; type(Point)", "<type STRUCT>"},
+		{"struct Point { x, y }; map([1, 2], fn(i) { Point(i, i) })", "[Point{x: 1, y: 1}, Point{x: 2, y: 2}]"},
+		{"struct Point { x, y }; Point(1, 2) |> type", "<type Point>"},
+		{`let h = {}; h["p"]?.x`, "null"},
+		{"struct Point { x, y }; Point(1)", "ERROR: wrong number of arguments to Point. got=1, want=2"},
+		{"struct Point { x, y }; Point(1, 2, 3)", "ERROR: wrong number of arguments to Point. got=3, want=2"},
+		{"struct Point { x, y }; Point(1, 2).z", "ERROR: Point has no field or method z"},
+		{"struct Point { x, y }; let p = Point(1, 2); p.z = 1", "ERROR: Point has no field z"},
+		{"let h = {}; h.x", "ERROR: HASH_OBJ has no field or method x"},
+		{"let h = {}; h.x = 1", "ERROR: HASH_OBJ has no field x"},
+		{"struct INTEGER { x }", "ERROR: struct INTEGER conflicts with built-in type"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.NotNil(t, evaluated, "no result for %s", tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2].push(3)", "[1, 2, 3]"},
+		{`"abc".upper()`, "ABC"},
+		{`{"b": 2, "a": 1}.keys()`, "[a, b]"},
+		{"[3, 1, 2].sort().reverse().first()", "3"},
+		{"[1, 2, 3].map(x => x * 2).filter(x => x > 2)", "[4, 6]"},
+		{`"a,b".split(",").join("-")`, "a-b"},
+		{`"%d-%s".format(1, "a")`, "1-a"},
+		{"let push = [1].push; push(2)", "[1, 2]"},
+		{"[1].push", "<method ARRAY_OBJ.push>"},
+		{"type([1].push)", "<type METHOD>"},
+		{"map([[1], [2]], [0].concat)", "[[0, 1], [0, 2]]"},
+		{"let ch = channel(1); ch.send(1); ch.recv()", "1"},
+		{"let add = fn(a, b) { a + b }; add.spawn(1, 2).await()", "3"},
+		{`method(type(1), "double", fn(n) { n * 2 }); 21.double()`, "42"},
+		{`method(type(""), "shout", fn(s, suffix = "!") { s.upper() + suffix }); "hi".shout("?")`, "HI?"},
+		{`method(type([]), "second", fn(arr) { arr[1] }); [1, 2].second()`, "2"},
+		{`struct Vec { x, y };
+		method(Vec, "add", fn(a, b) { Vec(a.x + b.x, a.y + b.y) });
+		Vec(1, 2).add(Vec(3, 4))`, "Vec{x: 4, y: 6}"},
+		{`struct Box { value };
+		method(Box, "value", fn(b) { "method" });
+		Box(1).value`, "1"},
+		{`struct Counter { n };
+		method(Counter, "inc", fn(c) { c.n = c.n + 1; c });
+		Counter(0).inc().inc().n`, "2"},
+		{`let h = {}; h["x"]?.upper()`, "null"},
+		{`[1, 2].len() + "abc".len() + {"a": 1}.len()`, "6"},
+		{`1.len()`, "ERROR: INTEGER has no field or method len"},
+		{`"abc".missing()`, "ERROR: STRING has no field or method missing"},
+		{`"abc".repeat("x")`, "ERROR: argument to second must be INTEGER, got STRING"},
+		{`method(1, "x", fn(n) { n })`, "ERROR: argument to first must be OBJECT_TYPE or STRUCT, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+
+	methods := testEval(`methods("")`).Inspect()
+	require.Contains(t, methods, "upper")
+	require.NotContains(t, methods, "push")
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vec := `struct Vec { x, y };
+	method(Vec, "+", fn(a, b) { Vec(a.x + b.x, a.y + b.y) });
+	let scale = fn(v, k) { Vec(v.x * k, v.y * k) };
+	method(Vec, "*", fn(a, b) { str(type(a)) == "<type Vec>" ? scale(a, b) : scale(b, a) });
+	method(Vec, "==", fn(a, b) { a.x == b.x ? a.y == b.y : false });
+	method(Vec, "<", fn(a, b) { a.x * a.x + a.y * a.y < b.x * b.x + b.y * b.y });
+	method(Vec, "[]", fn(v, i) { i == 0 ? v.x : v.y });
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vec + "Vec(1, 2) + Vec(3, 4)", "Vec{x: 4, y: 6}"},
+		{vec + "Vec(1, 2) * 3", "Vec{x: 3, y: 6}"},
+		{vec + "2 * Vec(1, 2)", "Vec{x: 2, y: 4}"},
+		{vec + "Vec(1, 2) == Vec(1, 2)", "true"},
+		{vec + "Vec(1, 2) != Vec(1, 2)", "false"},
+		{vec + "Vec(1, 2) != Vec(2, 1)", "true"},
+		{vec + "Vec(1, 2) < Vec(3, 4)", "true"},
+		{vec + "Vec(1, 2) > Vec(3, 4)", "false"},
+		{vec + "Vec(5, 0) > Vec(3, 0)", "true"},
+		{vec + "let v = Vec(7, 8); [v[0], v[1]]", "[7, 8]"},
+		{vec + "Vec(1, 2) - Vec(1, 2)", "ERROR: unknown operator: Vec - Vec"},
+		{vec + "Vec(1, 2) + true", "ERROR: BOOLEAN has no field or method x"},
+		{`struct Money { cents };
+		method(Money, "-", fn(a, b) { Money(a.cents - b.cents) });
+		Money(500) - Money(150)`, "Money{cents: 350}"},
+		{`method(type({}), "+", fn(a, b) { merge(a, b) }); items({"a": 1} + {"b": 2})`, `[[a, 1], [b, 2]]`},
+		{`{"a": 1} + {"b": 2}`, "ERROR: unknown operator: HASH_OBJ + HASH_OBJ"},
+		{`struct Box { value }; Box(1)[0]`, "ERROR: index operator not supported:Box"},
+		{`struct Box { value }; Box(1) == Box(1)`, "false"},
+		{`struct P { x }; method(P, "+", fn(a, b) { P(a.x + b.x) });
+		let p = P(1);
+		struct P { x, y };
+		P(1, 2) + P(3, 4)`, "ERROR: unknown operator: P + P"},
+		{`struct P { x }; method(P, "+", fn(a, b) { P(a.x + b.x) });
+		let q = P(2);
+		let f = fn() { struct P { x }; P(1) + P(1) };
+		(q + q).x == 4 ? f() : "outer method lost"`, "ERROR: unknown operator: P + P"},
+		{`struct P { x }; let p = P(1); method(type(p), "double", fn(v) { v.x * 2 }); [p.double(), methods(p)]`, "[2, [double]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"await(spawn(fn(a, b) { a + b }, 1, 2))", "3"},
+		{"await(spawn(len, [1, 2]))", "2"},
+		{"let tasks = map(range(5), fn(i) { spawn(fn() { i * i }) }); map(tasks, await)", "[0, 1, 4, 9, 16]"},
+		{"let x = 10; let add = fn(n) { x + n }; map(map(range(3), fn(i) { spawn(add, i) }), await)", "[10, 11, 12]"},
+		{"let ch = channel(); spawn(fn() { send(ch, 1); send(ch, 2); close(ch) }); [recv(ch), recv(ch), recv(ch)]", "[1, 2, null]"},
+		{"let ch = channel(3); send(ch, 1); send(ch, 2); close(ch); collect(ch)", "[1, 2]"},
+		{`let ch = channel();
+		let produce = fn(n) { for (i in range(n)) { send(ch, i) }; close(ch) };
+		spawn(produce, 4);
+		reduce(collect(ch), 0, fn(sum, x) { sum + x })`, "6"},
+		{`let results = channel(3);
+		let square = fn(x) { send(results, x * x) };
+		let tasks = map([1, 2, 3], fn(x) { spawn(square, x) });
+		map(tasks, await);
+		close(results);
+		sort(collect(results))`, "[1, 4, 9]"},
+		{"let a = channel(); let b = channel(1); send(b, 5); select([a, b])", "[1, 5]"},
+		{"let a = channel(); close(a); select([a])", "[0, null]"},
+		{"let t = spawn(fn() { 1 }); await(t); t", "<task done>"},
+		{"type(channel())", "<type CHANNEL>"},
+		{"await(spawn(fn() { 1 + true }))", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let ch = channel(1); close(ch); send(ch, 1)", "ERROR: send on closed channel"},
+		{"let ch = channel(); close(ch); close(ch)", "ERROR: close of closed channel"},
+		{"channel(-1)", "ERROR: channel size must not be negative, got -1"},
+		{"channel(9223372036854775807)", "ERROR: channel size must not exceed 1048576, got 9223372036854775807"},
+		{"select([])", "ERROR: select needs at least one channel"},
+		{"select([1])", "ERROR: not a channel: INTEGER"},
+		{"await(1)", "ERROR: argument to first must be TASK, got INTEGER"},
+		{`struct Counter { n };
+		let c = Counter(0);
+		let tasks = map(range(4), fn(i) { spawn(fn() { c.n = i; c.n }) });
+		map(tasks, await);
+		type(c.n)`, "<type INTEGER>"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestLazyBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"collect(take(iterate(x => x * 2, 1), 5))", "[1, 2, 4, 8, 16]"},
+		{"collect(take(lazyMap(iterate(x => x + 1, 1), x => x * x), 3))", "[1, 4, 9]"},
+		{"collect(take(lazyFilter(iterate(x => x + 1, 1), x => x > 3), 2))", "[4, 5]"},
+		{"collect(lazyMap([1, 2], x => x + 1))", "[2, 3]"},
+		{`collect(lazyMap({"a": 1}, pair => pair[0]))`, "[a]"},
+		{`collect(take("hello", 2))`, "[h, e]"},
+		{"collect(take([1, 2], 5))", "[1, 2]"},
+		{"iterate(x => x, 0) |> lazyMap(x => x + 1) |> take(2) |> collect", "[1, 1]"},
+		{"collect(lazyMap([1], x => x + true))", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"collect(take(iterate(x => x + true, 1), 3))", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"take(1, 2)", "ERROR: argument to first must be ARRAY, HASH, STRING, ITERATOR or CHANNEL, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
 func TestBuiltinFunction(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -252,6 +751,10 @@ func TestBuiltinFunction(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(2)`, "argument to len not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`push([1])`, "wrong number of arguments. got=1, want=2"},
+		{`last(1)`, "argument to first must be ARRAY, got INTEGER"},
+		{`reduce([1], 0)`, "wrong number of arguments. got=2, want=3"},
+		{`range(1, 2, 3, 4)`, "wrong number of arguments. got=4, want=1 to 3"},
 	}
 
 	for _, tt := range tests {
@@ -268,6 +771,23 @@ func TestBuiltinFunction(t *testing.T) {
 	}
 }
 
+func TestBuiltinInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len`, "built-in function len(value)"},
+		{`sort`, "built-in function sort(arr: ARRAY, [comparator: FUNCTION | BUILTIN | METHOD])"},
+		{`puts`, "built-in function puts([...values])"},
+		{`help(first)`, "first(arr: ARRAY)\n\treturn the first element of arr, or null if arr is empty"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := `[1, 2 * 2, 3 + 3]`
 	evaluated := testEval(input)
@@ -295,7 +815,9 @@ func TestArrayIndexExpressions(t *testing.T) {
 		{`let myArray = [1,2,3]; myArray[0] + myArray[1] + myArray[2]`, 6},
 		{`let myArray = [1,2,3]; let i = myArray[0]; myArray[i]`, 2},
 		{`[1,2,3][3]`, nil},
-		{`[1,2,3][-1]`, nil},
+		{`[1,2,3][-1]`, 3},
+		{`[1,2,3][-3]`, 1},
+		{`[1,2,3][-4]`, nil},
 	}
 
 	for _, tt := range tests {
@@ -310,6 +832,78 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][1:10]`, "[2, 3, 4]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`"안녕하세요"[1:3]`, "녕하"},
+		{`"monkey"[-3:]`, "key"},
+		{`{"a": 1}[0:1]`, "ERROR: slice operator not supported: HASH_OBJ"},
+		{`[1, 2][true:]`, "ERROR: slice bound must be INTEGER, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// nothing but a comment", "null"},
+		{"let a = 6; // six\n// skipped: a = 0\na / 2 // three", "3"},
+		{`"http://example.com" // the scheme is kept`, "http://example.com"},
+		{"let f = fn(x) { // doubles x\n  x * 2 // result\n};\nf(2)", "4"},
+		{"[1, // first\n 2]", "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestStrictIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][3]`, "ERROR: index out of range: 3 with length 3"},
+		{`[1, 2, 3][-4]`, "ERROR: index out of range: -4 with length 3"},
+		{`"abc"[5]`, "ERROR: index out of range: 5 with length 3"},
+		{`"abc"[100000000000000000000]`, "ERROR: index out of range: 100000000000000000000 with length 3"},
+		{`[1, 2, 3][1:]`, "[2, 3]"},
+		{`[1, 2, 3][1:10]`, "ERROR: slice bound out of range: 10 with length 3"},
+		{`[1, 2, 3][:-100000000000000000000]`, "ERROR: slice bound out of range: -100000000000000000000 with length 3"},
+		{`[1, 2, 3][2:1]`, "ERROR: slice bounds out of range: [2:1]"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Runtime().StrictIndexing = true
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+
+	// other interpreters are not strict
+	require.Equal(t, "null", testEval(`[1, 2, 3][3]`).Inspect())
+}
+
 func TestHashLiteral(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -389,6 +983,141 @@ func TestHashIndexExpression(t *testing.T) {
 	}
 }
 
+func TestHashBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys({"b": 2, "a": 1, 3: 3})`, "[3, a, b]"},
+		{`values({"b": 2, "a": 1})`, "[1, 2]"},
+		{`items({"b": 2, "a": 1})`, "[[a, 1], [b, 2]]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`keys(delete({"a": 1, "b": 2}, "a"))`, "[b]"},
+		{`let h = {"a": 1}; delete(h, "a"); len(h)`, "1"},
+		{`items(merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4}))`, "[[a, 1], [b, 3], [c, 4]]"},
+		{`keys([1])`, "ERROR: argument to first must be HASH, got ARRAY"},
+		{`has({}, fn(x) {x})`, "ERROR: unhashable as hash key: FUNCTION"},
+		{`merge({}, 1)`, "ERROR: argument to second must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestArrayBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`find([1, 2, 3, 4], fn(x) { x > 2 })`, "3"},
+		{`find([1, 2], fn(x) { x > 2 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x == 2 })`, "true"},
+		{`any([], fn(x) { true })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([3, 1, 2], fn(a, b) { a - b })`, "[1, 2, 3]"},
+		{`sort([1, "a"])`, "ERROR: unable to compare STRING and INTEGER"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], 2)`, "[3, 4]"},
+		{`slice([1, 2, 3, 4], -2)`, "[3, 4]"},
+		{`slice([1, 2, 3, 4], 3, 1)`, "[]"},
+		{`concat([1], [2, 3], [])`, "[1, 2, 3]"},
+		{`range(3)`, "[0, 1, 2]"},
+		{`range(1, 4)`, "[1, 2, 3]"},
+		{`range(5, 0, -2)`, "[5, 3, 1]"},
+		{`range(0, 1, 0)`, "ERROR: range step must not be zero"},
+		{`range(0, 9223372036854775807, 4611686018427387904)`, "[0, 4611686018427387904]"},
+		{`range(9223372036854775807, -9223372036854775807, -9223372036854775807)`, "[9223372036854775807, 0]"},
+		{`range(-5, 5, 4)`, "[-5, -1, 3]"},
+		{`range(9223372036854775807)`, "ERROR: range is too long, limit is 67108864 elements"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`flatten([1, [2, 3], [[4]]])`, "[1, 2, 3, [4]]"},
+		{`unique([1, 2, 1, "a", "a"])`, "[1, 2, a]"},
+		{`filter(1, fn(x) { x })`, "ERROR: argument to first must be ARRAY, got INTEGER"},
+		{`filter([1], 1)`, "ERROR: argument to second must be FUNCTION, BUILTIN or METHOD, got INTEGER"},
+		{`filter([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestStringBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("한글")`, "2"},
+		{`str(1)`, "1"},
+		{`str([1, true])`, "[1, true]"},
+		{`type(str(1))`, "<type STRING>"},
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`split("  a b  c ")`, "[a, b, c]"},
+		{`join(["a", "b", 1], "-")`, "a-b-1"},
+		{`join(["a", "b"])`, "ab"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("--hi--", "-")`, "hi"},
+		{`upper("éa")`, "ÉA"},
+		{`lower("ÀB")`, "àb"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`contains("monkey", "key")`, "true"},
+		{`startsWith("monkey", "mon")`, "true"},
+		{`endsWith("monkey", "mon")`, "false"},
+		{`indexOf("가나다", "다")`, "2"},
+		{`indexOf("abc", "z")`, "-1"},
+		{`substr("안녕하세요", 1, 2)`, "녕하"},
+		{`substr("안녕하세요", -2)`, "세요"},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "ERROR: repeat count must not be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, "ERROR: repeat result is too long, limit is 1073741824 bytes"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`chars("héllo")`, "[h, é, l, l, o]"},
+		{`format("%s has %d items: %v", "cart", 2, [1, 2])`, "cart has 2 items: [1, 2]"},
+		{`sprintf("%t", true)`, "true"},
+		{`upper(1)`, "ERROR: argument to first must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "wrong result for %s", tt.input)
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"héllo"[1]`, "é"},
+		{`let s = "monkey"; s[len(s) - 1]`, "y"},
+		{`"abc"[3]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, expected, evaluated)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 //
 // Helper functions
 func testEval(input string) object.Object {
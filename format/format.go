@@ -0,0 +1,615 @@
+package format
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// ParseError is returned for source which does not parse
+type ParseError struct {
+	Diagnostics []parser.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	messages := []string{}
+	for _, d := range e.Diagnostics {
+		messages = append(messages, d.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// format source in the canonical style, keeping its comments
+//
+// statements go on their own lines, indented by tabs and terminated by
+// semicolons. blocks and hash literals written on a single line stay on a
+// single line. a single blank line between statements is kept. comments
+// within expressions are moved after the statement containing them
+func Source(source string) (string, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", &ParseError{Diagnostics: p.Diagnostics()}
+	}
+
+	pr := newPrinter(source)
+	pr.program(program)
+	return pr.out.String(), nil
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+	// true until the first item of the current block is printed
+	first bool
+	// greater than 0 while printing on a single line
+	inline int
+	// true if the current line ends with a comment
+	commented bool
+
+	elements []token.Token          // tokens and comments of source in order
+	comments []token.Token          // comments not printed yet
+	closing  map[[2]int]token.Token // closing brace by position of the opening one
+	eof      token.Token
+}
+
+func newPrinter(source string) *printer {
+	p := &printer{first: true, closing: map[[2]int]token.Token{}}
+
+	l := lexer.New(source)
+	opening := []token.Token{}
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			p.eof = tok
+			break
+		}
+		p.elements = append(p.elements, tok)
+
+		switch tok.Type {
+		case token.COMMENT:
+			p.comments = append(p.comments, tok)
+		case token.L_BRACE, token.L_PAREN, token.L_BRACKET:
+			opening = append(opening, tok)
+		case token.R_BRACE, token.R_PAREN, token.R_BRACKET:
+			if len(opening) > 0 {
+				open := opening[len(opening)-1]
+				opening = opening[:len(opening)-1]
+				p.closing[position(open)] = tok
+			}
+		}
+	}
+	return p
+}
+
+func position(tok token.Token) [2]int {
+	return [2]int{tok.Line, tok.Column}
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// line where tok ends, which differs from its start for multi-line strings
+func endLine(tok token.Token) int {
+	return tok.Line + strings.Count(tok.Literal, "\n")
+}
+
+// source element right before tok, the zero token if there is none
+func (p *printer) previous(tok token.Token) token.Token {
+	i := sort.Search(len(p.elements), func(i int) bool {
+		return !before(p.elements[i], tok)
+	})
+	if i == 0 {
+		return token.Token{}
+	}
+	return p.elements[i-1]
+}
+
+// closing brace of block or hash literal opened by tok, the zero token if
+// it has none like bodies of arrow functions without braces
+func (p *printer) closingOf(tok token.Token) token.Token {
+	return p.closing[position(tok)]
+}
+
+func (p *printer) hasCommentsBefore(tok token.Token) bool {
+	return len(p.comments) > 0 && before(p.comments[0], tok)
+}
+
+// start a new line for an item located at tok, keeping a blank line before it
+func (p *printer) newline(tok token.Token) {
+	if p.out.Len() > 0 {
+		if !p.first && tok.Line-endLine(p.previous(tok)) > 1 {
+			p.out.WriteString("\n")
+		}
+		p.linebreak()
+		return
+	}
+	p.out.WriteString(strings.Repeat("\t", p.indent))
+	p.first = false
+}
+
+func (p *printer) linebreak() {
+	p.out.WriteString("\n" + strings.Repeat("\t", p.indent))
+	p.first = false
+	p.commented = false
+}
+
+// print comments located before tok. the next output must start a new line
+func (p *printer) flushComments(tok token.Token) {
+	if p.inline > 0 {
+		return
+	}
+	for p.hasCommentsBefore(tok) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+		text := strings.TrimRight(comment.Literal, " \t")
+
+		previous := p.previous(comment)
+		if p.out.Len() > 0 && !p.commented && previous.Type != token.COMMENT && endLine(previous) == comment.Line {
+			p.out.WriteString(" " + text)
+		} else {
+			p.newline(comment)
+			p.out.WriteString(text)
+		}
+		p.commented = true
+	}
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, p.eof)
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+}
+
+// print statements on their own lines, followed by the comments before end
+func (p *printer) statements(statements []ast.Statement, end token.Token) {
+	for i, statement := range statements {
+		start := startOf(statement)
+		p.flushComments(start)
+		p.newline(start)
+		p.statement(statement)
+
+		var next ast.Statement
+		if i+1 < len(statements) {
+			next = statements[i+1]
+		}
+		p.out.WriteString(p.terminator(statement, next))
+	}
+	p.flushComments(end)
+}
+
+// semicolon after statement, which is omitted after blocks unless the next
+// statement would continue the expression like "(x)" or "-1"
+func (p *printer) terminator(statement ast.Statement, next ast.Statement) string {
+	switch statement := statement.(type) {
+	case *ast.ForStatement, *ast.StructStatement:
+		return ""
+	case *ast.ExpressionStatement:
+		if _, ok := statement.Expression.(*ast.IfExpression); !ok {
+			return ";"
+		}
+		if next == nil {
+			return ""
+		}
+		switch p.sketch(next)[0] {
+		case '(', '[', '-':
+			return ";"
+		}
+		return ""
+	default:
+		return ";"
+	}
+}
+
+// print node on a single line without comments, to look at its shape
+func (p *printer) sketch(node ast.Node) string {
+	sketch := &printer{inline: 1, closing: p.closing}
+	switch node := node.(type) {
+	case ast.Statement:
+		sketch.statement(node)
+	case ast.Expression:
+		sketch.expression(node)
+	}
+	return sketch.out.String()
+}
+
+func (p *printer) statement(statement ast.Statement) {
+	switch s := statement.(type) {
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+	case *ast.LetStatement:
+		p.out.WriteString("let ")
+		if s.Pattern != nil {
+			p.expression(s.Pattern)
+		} else {
+			p.expression(s.Name)
+		}
+		p.out.WriteString(" = ")
+		p.expression(s.Value)
+	case *ast.ReturnStatement:
+		p.out.WriteString("return ")
+		p.expression(s.ReturnValue)
+	case *ast.ForStatement:
+		p.out.WriteString("for (")
+		if s.Key != nil {
+			p.expression(s.Key)
+			p.out.WriteString(", ")
+		}
+		p.expression(s.Value)
+		p.out.WriteString(" in ")
+		p.expression(s.Iterable)
+		p.out.WriteString(") ")
+		p.block(s.Body)
+	case *ast.BreakStatement:
+		p.out.WriteString("break")
+	case *ast.ContinueStatement:
+		p.out.WriteString("continue")
+	case *ast.YieldStatement:
+		p.out.WriteString("yield")
+		if s.Value != nil {
+			p.out.WriteString(" ")
+			p.expression(s.Value)
+		}
+	case *ast.StructStatement:
+		p.out.WriteString("struct ")
+		p.expression(s.Name)
+		if len(s.Fields) == 0 {
+			p.out.WriteString(" {}")
+			return
+		}
+		fields := []string{}
+		for _, field := range s.Fields {
+			fields = append(fields, field.Value)
+		}
+		p.out.WriteString(" { " + strings.Join(fields, ", ") + " }")
+	case *ast.AssignStatement:
+		p.expression(s.Target)
+		p.out.WriteString(" = ")
+		p.expression(s.Value)
+	}
+}
+
+// print block in braces, on a single line if it was written so
+func (p *printer) block(block *ast.BlockStatement) {
+	closing := p.closingOf(block.Token)
+	oneLine := closing.Line == block.Token.Line && len(block.Statements) <= 1
+	if p.inline > 0 || oneLine {
+		if len(block.Statements) == 0 {
+			p.out.WriteString("{}")
+			return
+		}
+		p.inline++
+		p.out.WriteString("{ ")
+		for i, statement := range block.Statements {
+			if i > 0 {
+				p.out.WriteString("; ")
+			}
+			p.statement(statement)
+		}
+		p.out.WriteString(" }")
+		p.inline--
+		return
+	}
+
+	if len(block.Statements) == 0 && !p.hasCommentsBefore(closing) {
+		p.out.WriteString("{}")
+		return
+	}
+
+	p.out.WriteString("{")
+	p.indent++
+	p.first = true
+	p.statements(block.Statements, closing)
+	p.indent--
+	p.linebreak()
+	p.out.WriteString("}")
+}
+
+// precedence of atoms like literals, which never need parentheses
+const atom = parser.INDEX + 1
+
+// binding power of expression, to find where parentheses are needed
+func precedence(expression ast.Expression) int {
+	switch e := expression.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PipeExpression:
+		return parser.PIPE
+	case *ast.ConditionalExpression:
+		return parser.CONDITIONAL
+	case *ast.FunctionLiteral:
+		// body of arrow function without braces extends as far as possible
+		if e.Token.Type == token.ARROW && e.Body.Token.Type == token.ARROW {
+			return parser.LOWEST
+		}
+		return atom
+	case *ast.PrefixExpression, *ast.SpreadExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return parser.INDEX
+	default:
+		return atom
+	}
+}
+
+// print expression, in parentheses if it binds looser than minimum
+func (p *printer) operand(expression ast.Expression, minimum int) {
+	if precedence(expression) < minimum {
+		p.out.WriteString("(")
+		p.expression(expression)
+		p.out.WriteString(")")
+		return
+	}
+	p.expression(expression)
+}
+
+func (p *printer) expressions(expressions []ast.Expression) {
+	for i, expression := range expressions {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(expression)
+	}
+}
+
+func (p *printer) expression(expression ast.Expression) {
+	switch e := expression.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(e.Value)
+	case *ast.IntegerLiteral:
+		p.out.WriteString(e.Token.Literal)
+	case *ast.BigIntegerLiteral:
+		p.out.WriteString(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.out.WriteString(`"` + e.Token.Literal + `"`)
+	case *ast.InterpolatedString:
+		p.out.WriteString(`"` + e.Token.Literal + `"`)
+	case *ast.Boolean:
+		p.out.WriteString(e.Token.Literal)
+	case *ast.PrefixExpression:
+		p.out.WriteString(e.Operator)
+		// "--x" would read like a decrement
+		if e.Operator == "-" && strings.HasPrefix(p.sketch(e.Right), "-") {
+			p.operand(e.Right, atom+1)
+			return
+		}
+		p.operand(e.Right, parser.PREFIX)
+	case *ast.SpreadExpression:
+		p.out.WriteString("...")
+		p.operand(e.Value, parser.PREFIX)
+	case *ast.InfixExpression:
+		// operators are left associative
+		precedence := parser.Precedence(e.Token.Type)
+		p.operand(e.Left, precedence)
+		p.out.WriteString(" " + e.Operator + " ")
+		p.operand(e.Right, precedence+1)
+	case *ast.PipeExpression:
+		p.operand(e.Left, parser.PIPE)
+		p.out.WriteString(" |> ")
+		p.operand(e.Right, parser.PIPE+1)
+	case *ast.ConditionalExpression:
+		p.operand(e.Condition, parser.CONDITIONAL+1)
+		p.out.WriteString(" ? ")
+		p.expression(e.Consequence)
+		p.out.WriteString(" : ")
+		p.expression(e.Alternative)
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(e.Condition)
+		p.out.WriteString(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.function(e)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		if e.Optional {
+			p.out.WriteString("?.")
+		}
+		p.out.WriteString("(")
+		p.expressions(e.Arguments)
+		p.out.WriteString(")")
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.expressions(e.Elements)
+		p.out.WriteString("]")
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.CALL)
+		if e.Optional {
+			p.out.WriteString("?.")
+		}
+		p.out.WriteString("[")
+		p.expression(e.Index)
+		p.out.WriteString("]")
+	case *ast.SliceExpression:
+		p.operand(e.Left, parser.CALL)
+		if e.Optional {
+			p.out.WriteString("?.")
+		}
+		p.out.WriteString("[")
+		if e.Start != nil {
+			p.expression(e.Start)
+		}
+		p.out.WriteString(":")
+		if e.End != nil {
+			p.expression(e.End)
+		}
+		p.out.WriteString("]")
+	case *ast.MemberExpression:
+		p.operand(e.Object, parser.CALL)
+		if e.Optional {
+			p.out.WriteString("?.")
+		} else {
+			p.out.WriteString(".")
+		}
+		p.out.WriteString(e.Member.Value)
+	case *ast.HashLiteral:
+		p.hash(e)
+	case *ast.ArrayPattern:
+		p.out.WriteString("[")
+		for i, element := range e.Elements {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.patternElement(element)
+		}
+		if e.Rest != nil {
+			if len(e.Elements) > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString("..." + e.Rest.Value)
+		}
+		p.out.WriteString("]")
+	case *ast.HashPattern:
+		p.out.WriteString("{")
+		for i, pair := range e.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			if target, ok := pair.Value.Target.(*ast.Identifier); !ok || target.Value != pair.Key.Value {
+				if pair.Key.Token.Type == token.STRING {
+					p.out.WriteString(`"` + pair.Key.Value + `": `)
+				} else {
+					p.out.WriteString(pair.Key.Value + ": ")
+				}
+			}
+			p.patternElement(pair.Value)
+		}
+		if e.Rest != nil {
+			if len(e.Pairs) > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString("..." + e.Rest.Value)
+		}
+		p.out.WriteString("}")
+	}
+}
+
+func (p *printer) patternElement(element *ast.PatternElement) {
+	p.expression(element.Target)
+	if element.Default != nil {
+		p.out.WriteString(" = ")
+		p.expression(element.Default)
+	}
+}
+
+// print function literal, or arrow function like "(x, y) => x + y"
+func (p *printer) function(fl *ast.FunctionLiteral) {
+	parameters := []string{}
+	for _, parameter := range fl.Parameters {
+		if value, ok := fl.Defaults[parameter.Value]; ok {
+			parameters = append(parameters, parameter.Value+" = "+p.sketch(value))
+		} else {
+			parameters = append(parameters, parameter.Value)
+		}
+	}
+	if fl.Rest != nil {
+		parameters = append(parameters, "..."+fl.Rest.Value)
+	}
+
+	if fl.Token.Type != token.ARROW {
+		p.out.WriteString("fn(" + strings.Join(parameters, ", ") + ") ")
+		p.block(fl.Body)
+		return
+	}
+
+	if len(fl.Parameters) == 1 && fl.Rest == nil && len(fl.Defaults) == 0 {
+		p.out.WriteString(parameters[0] + " => ")
+	} else {
+		p.out.WriteString("(" + strings.Join(parameters, ", ") + ") => ")
+	}
+	if fl.Body.Token.Type != token.ARROW {
+		p.block(fl.Body)
+		return
+	}
+
+	// a body starting with "{" would be read as a block
+	body := fl.Body.Statements[0].(*ast.ExpressionStatement).Expression
+	if strings.HasPrefix(p.sketch(body), "{") {
+		p.out.WriteString("(")
+		p.expression(body)
+		p.out.WriteString(")")
+		return
+	}
+	p.expression(body)
+}
+
+// print hash literal, with a pair on each line if it was written so
+func (p *printer) hash(hash *ast.HashLiteral) {
+	keys := hash.Keys()
+	if len(keys) == 0 {
+		p.out.WriteString("{}")
+		return
+	}
+
+	if p.inline > 0 || startOf(keys[0]).Line == hash.Token.Line {
+		p.out.WriteString("{")
+		for i, key := range keys {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.expression(key)
+			p.out.WriteString(": ")
+			p.expression(hash.Pairs[key])
+		}
+		p.out.WriteString("}")
+		return
+	}
+
+	p.out.WriteString("{")
+	p.indent++
+	p.first = true
+	for _, key := range keys {
+		start := startOf(key)
+		p.flushComments(start)
+		p.newline(start)
+		p.expression(key)
+		p.out.WriteString(": ")
+		p.expression(hash.Pairs[key])
+		p.out.WriteString(",")
+	}
+	p.flushComments(p.closingOf(hash.Token))
+	p.indent--
+	p.linebreak()
+	p.out.WriteString("}")
+}
+
+// first token of node in source, as far as the tree tells
+func startOf(node ast.Node) token.Token {
+	switch n := node.(type) {
+	case *ast.AssignStatement:
+		return startOf(n.Target)
+	case *ast.InfixExpression:
+		return startOf(n.Left)
+	case *ast.PipeExpression:
+		return startOf(n.Left)
+	case *ast.ConditionalExpression:
+		return startOf(n.Condition)
+	case *ast.CallExpression:
+		return startOf(n.Function)
+	case *ast.IndexExpression:
+		return startOf(n.Left)
+	case *ast.SliceExpression:
+		return startOf(n.Left)
+	case *ast.MemberExpression:
+		return startOf(n.Object)
+	case *ast.FunctionLiteral:
+		if n.Token.Type == token.ARROW && len(n.Parameters) > 0 {
+			return n.Parameters[0].Token
+		}
+		return n.Token
+	default:
+		return ast.NodeToken(node)
+	}
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		source, err := ioutil.ReadFile(input)
+		require.NoError(t, err)
+
+		formatted, err := Source(string(source))
+		require.NoError(t, err, "cannot format %s", input)
+
+		golden := strings.TrimSuffix(input, ".input") + ".golden"
+		if *update {
+			require.NoError(t, ioutil.WriteFile(golden, []byte(formatted), 0644))
+		}
+		expected, err := ioutil.ReadFile(golden)
+		require.NoError(t, err)
+		require.Equal(t, string(expected), formatted, "wrong format of %s", input)
+
+		again, err := Source(formatted)
+		require.NoError(t, err)
+		require.Equal(t, formatted, again, "format of %s is not idempotent", input)
+
+		require.Equal(t, shape(t, string(source)), shape(t, formatted), "format of %s changed its meaning", input)
+	}
+}
+
+// describe every node of parsed source in walking order, ignoring positions
+func shape(t *testing.T, source string) []string {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+
+	nodes := []string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case nil:
+			nodes = append(nodes, "end")
+		case *ast.ExpressionStatement:
+			// the token of statement is a parenthesis if it starts with one
+			nodes = append(nodes, "*ast.ExpressionStatement")
+		default:
+			nodes = append(nodes, fmt.Sprintf("%T %s", node, node.TokenLiteral()))
+		}
+		return true
+	})
+	return nodes
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source("let x = 1;\nlet = 2;")
+	require.Error(t, err)
+	parseError, ok := err.(*ParseError)
+	require.True(t, ok, "error is not ParseError, %T", err)
+	require.Len(t, parseError.Diagnostics, 1)
+	require.Equal(t, "2:5: error: expected next token to be IDENTIFIER, got = instead", err.Error())
+}
+
+func TestEmptySource(t *testing.T) {
+	for _, input := range []string{"", "\n\n", "  "} {
+		formatted, err := Source(input)
+		require.NoError(t, err)
+		require.Equal(t, "", formatted)
+	}
+
+	formatted, err := Source("\n// only a comment  \n")
+	require.NoError(t, err)
+	require.Equal(t, "// only a comment\n", formatted)
+}
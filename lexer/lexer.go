@@ -1,30 +1,65 @@
 package lexer
 
-import "monkey/token"
+import (
+	"io"
+	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
+// Lexer scans UTF-8 input rune by rune
 type Lexer struct {
-	input        string
-	position     int
-	readPosition int
-	ch           byte
+	input        []byte // whole input, or buffered part of reader
+	position     int    // byte offset of ch in input
+	readPosition int    // byte offset of the rune after ch in input
+	ch           rune   // 0 at the end of input
+	line         int
+	column       int
+
+	reader io.Reader // nil once it is exhausted
+	err    error
+
+	unterminated bool // input ended inside the last string literal
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: []byte(input), line: 1}
 	l.readChar()
 	return l
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+// create lexer for input which is embedded in other source at 1-based line
+// and column, so that its tokens are located in that source
+func NewAt(input string, line, column int) *Lexer {
+	l := New(input)
+	l.line = line
+	l.column = column
+	return l
+}
 
+func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespaces()
+	l.discardConsumed()
+
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line = line
+	tok.Column = column
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: "=="}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -42,11 +77,32 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '<' {
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: "<<"}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: ">>"}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		tok = newToken(token.BIT_AND, l.ch)
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -65,6 +121,31 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.R_BRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '?':
+		if l.peekChar() == '?' {
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		} else if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_CHAIN, Literal: "?."}
+		} else {
+			tok = newToken(token.QUESTION, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: "|>"}
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -89,54 +170,129 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// advance to the next rune and keep track of its line and column
+//
+// invalid UTF-8 is read as utf8.RuneError one byte at a time
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
 	}
+	// the column stays at the end of input
+	if l.readPosition > l.position || l.column == 0 {
+		l.column++
+	}
+
 	l.position = l.readPosition
-	l.readPosition += 1
+	if !l.buffered(l.readPosition) {
+		l.ch = 0
+		return
+	}
+	ch, width := utf8.DecodeRune(l.input[l.readPosition:])
+	l.ch = ch
+	l.readPosition += width
 }
 
 // look ahead char
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(1)
+}
+
+// look ahead n-th char from current char
+func (l *Lexer) peekCharAt(n int) rune {
+	position := l.readPosition
+	for ; n > 1; n-- {
+		if !l.buffered(position) {
+			return 0
+		}
+		_, width := utf8.DecodeRune(l.input[position:])
+		position += width
+	}
+	if !l.buffered(position) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	ch, _ := utf8.DecodeRune(l.input[position:])
+	return ch
 }
 
-// read identifiers from lexer's input string
+// read identifier, which is a letter followed by letters and digits
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	return string(l.input[position:l.position])
 }
 
-// read numbers from lexer's input string
+// read integer literal like 1_000, 0xff, 0o17 or 0b1010
+//
+// letters are read as part of the literal, so that malformed literals like
+// 0b12 or 1abc are rejected by the parser as a whole
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for isDigit(l.ch) {
+	for isDigit(l.ch) || isLetter(l.ch) {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	return string(l.input[position:l.position])
 }
 
+// read string literal from lexer's input string
+//
+// quotes inside of interpolations like "${f("x")}" don't terminate the string
 func (l *Lexer) readString() string {
 	position := l.position + 1
+	depth := 0
+	inner := false
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == 0 {
+			l.unterminated = true
 			break
 		}
+		if depth == 0 {
+			if l.ch == '"' {
+				break
+			}
+			if l.ch == '\\' && l.peekChar() == '$' {
+				l.readChar()
+				continue
+			}
+			if l.ch == '$' && l.peekChar() == '{' {
+				l.readChar()
+				depth++
+			}
+			continue
+		}
+
+		switch {
+		case l.ch == '"':
+			inner = !inner
+		case inner:
+		case l.ch == '{':
+			depth++
+		case l.ch == '}':
+			depth--
+		}
 	}
-	return l.input[position:l.position]
+	return string(l.input[position:l.position])
+}
+
+// report whether input ended inside a string literal which is missing its
+// closing quote
+func (l *Lexer) Unterminated() bool {
+	return l.unterminated
+}
+
+// read comment like "// text" up to the end of line, excluding the line break
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return strings.TrimRight(string(l.input[position:l.position]), "\r")
 }
 
 // Skip whitespaces
@@ -147,16 +303,16 @@ func (l *Lexer) skipWhitespaces() {
 }
 
 // Construct token.Token object with arguments
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// return true if input arg is letter
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+// return true if input arg is letter, including letters out of ASCII
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-// return true if input arg is digit
-func isDigit(ch byte) bool {
+// return true if input arg is decimal digit
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -1,10 +1,16 @@
 package lexer
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNext(t *testing.T) {
@@ -144,3 +150,359 @@ if (5 < 10) {
 		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
 	}
 }
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"a ${f("}")} b" "\${" + x`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `a ${f("}")} b`},
+		{token.STRING, `\${`},
+		{token.PLUS, "+"},
+		{token.IDENTIFIER, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `fn(...rest) p.x ..`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.L_PAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "rest"},
+		{token.R_PAREN, ")"},
+		{token.IDENTIFIER, "p"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "x"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestArrowAndPipe(t *testing.T) {
+	input := `arr |> map(x => x) == | =`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "arr"},
+		{token.PIPE, "|>"},
+		{token.IDENTIFIER, "map"},
+		{token.L_PAREN, "("},
+		{token.IDENTIFIER, "x"},
+		{token.ARROW, "=>"},
+		{token.IDENTIFIER, "x"},
+		{token.R_PAREN, ")"},
+		{token.EQ, "=="},
+		{token.BIT_OR, "|"},
+		{token.ASSIGN, "="},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestNumbersAndBitwiseOperators(t *testing.T) {
+	input := `1_000 0xFF 0o17 0b1010 0b12 a & b | c ^ ~d << 2 >> 1 < >`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1_000"},
+		{token.INT, "0xFF"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "0b12"},
+		{token.IDENTIFIER, "a"},
+		{token.BIT_AND, "&"},
+		{token.IDENTIFIER, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENTIFIER, "c"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENTIFIER, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "2"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "1"},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestQuestionOperators(t *testing.T) {
+	input := `a ? b : c ?? h?.[0]`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENTIFIER, "b"},
+		{token.COLON, ":"},
+		{token.IDENTIFIER, "c"},
+		{token.NULLISH, "??"},
+		{token.IDENTIFIER, "h"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.L_BRACKET, "["},
+		{token.INT, "0"},
+		{token.R_BRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "let 변수 = \"héllo\"; x1 + _ÿ2 € \xff"
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "변수"},
+		{token.ASSIGN, "="},
+		{token.STRING, "héllo"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x1"},
+		{token.PLUS, "+"},
+		{token.IDENTIFIER, "_ÿ2"},
+		{token.ILLEGAL, "€"},
+		{token.ILLEGAL, "�"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// header\r\nlet x = 10 / 2; // half\n\"//not\"//"
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// header"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// half"},
+		{token.STRING, "//not"},
+		{token.COMMENT, "//"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let 변수 = \"é\";\n\tf(변수)\n"
+
+	testPositions := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"변수", 1, 5},
+		{"=", 1, 8},
+		{"é", 1, 10},
+		{";", 1, 13},
+		{"f", 2, 2},
+		{"(", 2, 3},
+		{"변수", 2, 4},
+		{")", 2, 6},
+		{"", 3, 1},
+		{"", 3, 1},
+	}
+
+	l := New(input)
+	for index, expected := range testPositions {
+		token := l.NextToken()
+		assert.Equal(t, expected.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+		assert.Equal(t, expected.expectedLine, token.Line, "Wrong line at %d", index)
+		assert.Equal(t, expected.expectedColumn, token.Column, "Wrong column at %d", index)
+	}
+}
+
+const streamingInput = `let 변수 = fn(x, y = 2, ...rest) { x + y * 0xff_ff };
+let s = "héllo ${변수(1)} \\${not}";
+[1, 2][0] |> str; h?.["k"] ?? -1;
+`
+
+func TestNewAt(t *testing.T) {
+	tokens := collectTokens(NewAt("a +\nb", 3, 7))
+
+	require.Len(t, tokens, 4)
+	assert.Equal(t, []int{3, 7}, []int{tokens[0].Line, tokens[0].Column})
+	assert.Equal(t, []int{3, 9}, []int{tokens[1].Line, tokens[1].Column})
+	assert.Equal(t, []int{4, 1}, []int{tokens[2].Line, tokens[2].Column})
+}
+
+func collectTokens(l *Lexer) []token.Token {
+	tokens := []token.Token{}
+	for iterator := l.Tokens(); ; {
+		tok, ok := iterator.Next()
+		tokens = append(tokens, tok)
+		if !ok {
+			return tokens
+		}
+	}
+}
+
+func TestReaderLexer(t *testing.T) {
+	tests := []string{
+		streamingInput,
+		strings.Repeat(streamingInput, 500),
+		// a token longer than many chunks
+		`"` + strings.Repeat("a", 1<<20) + `"`,
+	}
+
+	for _, input := range tests {
+		expected := collectTokens(New(input))
+		require.Equal(t, expected, collectTokens(NewReader(strings.NewReader(input))))
+		require.Equal(t, expected, collectTokens(NewReader(iotest.OneByteReader(strings.NewReader(input)))))
+	}
+}
+
+func TestReaderLexerError(t *testing.T) {
+	// the second read fails
+	l := NewReader(iotest.TimeoutReader(strings.NewReader("let x")))
+
+	tokens := collectTokens(l)
+	require.Equal(t, "let", tokens[0].Literal)
+	require.Equal(t, "x", tokens[1].Literal)
+	require.Equal(t, token.TokenType(token.EOF), tokens[2].Type)
+	require.True(t, errors.Is(l.Err(), iotest.ErrTimeout))
+
+	require.NoError(t, New("let x").Err())
+}
+
+// reader which never returns data nor an error
+type emptyReader struct{}
+
+func (emptyReader) Read(p []byte) (int, error) { return 0, nil }
+
+func TestReaderLexerNoProgress(t *testing.T) {
+	l := NewReader(emptyReader{})
+
+	require.Equal(t, token.TokenType(token.EOF), l.NextToken().Type)
+	require.True(t, errors.Is(l.Err(), io.ErrNoProgress))
+}
+
+func TestAppend(t *testing.T) {
+	for _, l := range []*Lexer{New("let x = 1;\n"), NewReader(strings.NewReader("let x = 1;\n"))} {
+		first := collectTokens(l)
+		require.Equal(t, 6, len(first))
+		require.Equal(t, token.TokenType(token.EOF), first[5].Type)
+
+		l.Append("x + 2")
+		second := collectTokens(l)
+		require.Equal(t, []token.Token{
+			{Type: token.IDENTIFIER, Literal: "x", Line: 2, Column: 1},
+			{Type: token.PLUS, Literal: "+", Line: 2, Column: 3},
+			{Type: token.INT, Literal: "2", Line: 2, Column: 5},
+			{Type: token.EOF, Literal: "", Line: 2, Column: 6},
+		}, second)
+	}
+
+	// input appended before the end of reader follows it
+	l := NewReader(strings.NewReader("a "))
+	l.Append("b")
+	tokens := collectTokens(l)
+	require.Equal(t, "a", tokens[0].Literal)
+	require.Equal(t, "b", tokens[1].Literal)
+}
+
+func TestTokensJSON(t *testing.T) {
+	tokens := collectTokens(New("let x = \"a\\tb\";"))
+
+	data, err := json.Marshal(tokens[:2])
+	require.NoError(t, err)
+	assert.Equal(t, `[{"type":"LET","literal":"let","line":1,"column":1},{"type":"IDENTIFIER","literal":"x","line":1,"column":5}]`, string(data))
+
+	data, err = json.Marshal(tokens)
+	require.NoError(t, err)
+	decoded := []token.Token{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, tokens, decoded)
+}
+
+func BenchmarkLexer(b *testing.B) {
+	input := strings.Repeat(streamingInput, 1000)
+
+	b.Run("string", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for i := 0; i < b.N; i++ {
+			l := New(input)
+			for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			}
+		}
+	})
+	b.Run("reader", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for i := 0; i < b.N; i++ {
+			l := NewReader(strings.NewReader(input))
+			for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			}
+		}
+	})
+}
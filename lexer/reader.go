@@ -0,0 +1,112 @@
+package lexer
+
+import (
+	"io"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// size of chunks read from the reader of streaming lexer
+const readChunkSize = 4096
+
+// number of reads in a row returning no data, after which the reader is
+// given up with io.ErrNoProgress
+const maxEmptyReads = 100
+
+// create lexer reading input from r on demand
+//
+// only the part of input from the current token is kept in memory
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{reader: r, line: 1}
+	l.readChar()
+	return l
+}
+
+// return the error, other than io.EOF, which stopped reading input
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// add input to be lexed after the current input
+//
+// once the lexer returned EOF, the following NextToken resumes lexing from
+// the added input; tokens already returned are never revised, so interactive
+// input should be appended line by line
+func (l *Lexer) Append(input string) {
+	if l.reader != nil {
+		l.reader = io.MultiReader(l.reader, strings.NewReader(input))
+		return
+	}
+
+	atEnd := l.ch == 0 && l.position == len(l.input)
+	l.input = append(l.input, input...)
+	if atEnd && l.position < len(l.input) {
+		ch, width := utf8.DecodeRune(l.input[l.position:])
+		l.ch = ch
+		l.readPosition = l.position + width
+	}
+}
+
+// TokenIterator yields tokens of lexer until EOF
+type TokenIterator struct {
+	l *Lexer
+}
+
+// return iterator of the remaining tokens
+func (l *Lexer) Tokens() *TokenIterator {
+	return &TokenIterator{l: l}
+}
+
+// return the next token, or EOF token and false at the end of input
+//
+// the iteration can be continued after Append
+func (ti *TokenIterator) Next() (token.Token, bool) {
+	tok := ti.l.NextToken()
+	return tok, tok.Type != token.EOF
+}
+
+// report whether input has a byte at offset, reading more input if needed
+//
+// a whole rune is buffered from offset unless input ends within it
+func (l *Lexer) buffered(offset int) bool {
+	for l.reader != nil && len(l.input)-offset < utf8.UTFMax {
+		l.fill()
+	}
+	return offset < len(l.input)
+}
+
+func (l *Lexer) fill() {
+	if cap(l.input)-len(l.input) < readChunkSize {
+		input := make([]byte, len(l.input), 2*cap(l.input)+readChunkSize)
+		copy(input, l.input)
+		l.input = input
+	}
+
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := l.reader.Read(l.input[len(l.input):cap(l.input)])
+		l.input = l.input[:len(l.input)+n]
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.reader = nil
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	l.err = io.ErrNoProgress
+	l.reader = nil
+}
+
+// drop input before the current token of streaming lexer
+func (l *Lexer) discardConsumed() {
+	if l.reader == nil || l.position < readChunkSize {
+		return
+	}
+	l.input = l.input[:copy(l.input, l.input[l.position:])]
+	l.readPosition -= l.position
+	l.position = 0
+}
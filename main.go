@@ -1,13 +1,43 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
+var strict = flag.Bool("strict", false, "raise an error for out of range index instead of yielding null")
+var plain = flag.Bool("plain", false, "print errors without color, for logs")
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s fmt [-w] [files...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s tokens [-json] [file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s ast [-json] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	repl.StrictIndexing = *strict
+	repl.Renderer.Color = !*plain && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stderr)
+
+	if flag.NArg() > 0 {
+		// a script named like a subcommand runs rather than the subcommand
+		if command, ok := commands[flag.Arg(0)]; ok && !fileExists(flag.Arg(0)) {
+			os.Exit(command(flag.Args()[1:]))
+		}
+		os.Exit(run(flag.Arg(0)))
+	}
+
+	// the REPL prints errors to stdout
+	repl.Renderer.Color = repl.Renderer.Color && isTerminal(os.Stdout)
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +45,41 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands!\n")
+	fmt.Printf("Type help() to list built-in functions.\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// evaluate file and return exit status
+func run(path string) int {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprint(os.Stderr, repl.Renderer.RenderAll(string(source), p.Diagnostics()))
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().StrictIndexing = *strict
+	if err, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		fmt.Fprint(os.Stderr, repl.Renderer.RenderError(string(source), err))
+		return 1
+	}
+	return 0
+}
+
+// report whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
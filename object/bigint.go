@@ -0,0 +1,51 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+)
+
+// BigInteger object holds integers out of the range of Integer
+//
+// it shares the INTEGER type with Integer, and results of arithmetic are
+// demoted to Integer whenever they fit, so that equal values are always
+// represented the same way
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+
+// values in the range of int64 hash like Integer
+func (bi *BigInteger) HashKey() HashKey {
+	if bi.Value.IsInt64() {
+		return HashKey{Type: bi.Type(), Value: uint64(bi.Value.Int64())}
+	}
+
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// create Integer if value fits in int64, or BigInteger otherwise
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInteger{Value: value}
+}
+
+// convert Integer or BigInteger into big.Int
+func ToBigInt(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInteger:
+		return obj.Value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,163 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Parameter of a built-in function
+//
+// a parameter with empty Types accepts objects of any type
+type BuiltinParameter struct {
+	Name     string
+	Types    []ObjectType
+	Optional bool
+}
+
+func (bp *BuiltinParameter) accepts(obj Object) bool {
+	if len(bp.Types) == 0 {
+		return true
+	}
+	for _, t := range bp.Types {
+		if obj.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (bp *BuiltinParameter) String() string {
+	if len(bp.Types) == 0 {
+		return bp.Name
+	}
+
+	types := make([]string, len(bp.Types))
+	for i, t := range bp.Types {
+		types[i] = TypeName(t)
+	}
+	return bp.Name + ": " + strings.Join(types, " | ")
+}
+
+// Signature of a built-in function
+//
+// if Variadic is true, the last parameter accepts any number of arguments
+type BuiltinSignature struct {
+	Name       string
+	Parameters []BuiltinParameter
+	Variadic   bool
+	Doc        string
+}
+
+// minimum and maximum number of arguments
+//
+// max is -1 if the signature is variadic
+func (bs *BuiltinSignature) Arity() (min int, max int) {
+	for _, p := range bs.Parameters {
+		if !p.Optional {
+			min++
+		}
+	}
+	if bs.Variadic {
+		return min, -1
+	}
+	return min, len(bs.Parameters)
+}
+
+// return an error object if args don't match the signature
+func (bs *BuiltinSignature) Validate(args []Object) *Error {
+	min, max := bs.Arity()
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", len(args), FormatArity(min, max))}
+	}
+
+	for i, arg := range args {
+		param := &bs.Parameters[len(bs.Parameters)-1]
+		if i < len(bs.Parameters) {
+			param = &bs.Parameters[i]
+		}
+
+		if !param.accepts(arg) {
+			return &Error{Message: fmt.Sprintf("argument to %s must be %s, got %s", ordinal(i), typesString(param.Types), TypeName(arg.Type()))}
+		}
+		// builtins taking integers only handle those in the range of int64
+		if _, ok := arg.(*BigInteger); ok && len(param.Types) > 0 {
+			return &Error{Message: fmt.Sprintf("%s argument to %s out of range: %s", ordinal(i), bs.Name, arg.Inspect())}
+		}
+	}
+	return nil
+}
+
+func (bs *BuiltinSignature) String() string {
+	var out bytes.Buffer
+
+	params := make([]string, len(bs.Parameters))
+	for i, p := range bs.Parameters {
+		param := p.String()
+		if bs.Variadic && i == len(bs.Parameters)-1 {
+			param = "..." + param
+		}
+		if p.Optional {
+			param = "[" + param + "]"
+		}
+		params[i] = param
+	}
+
+	out.WriteString(bs.Name)
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// describe expected number of arguments like "1", "1 or 2" or ">=1"
+//
+// max is -1 if there is no upper bound
+func FormatArity(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf(">=%d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	case min+1 == max:
+		return fmt.Sprintf("%d or %d", min, max)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+// name of objectType shown to users, like "ARRAY" for ARRAY_OBJ
+func TypeName(objectType ObjectType) string {
+	return strings.TrimSuffix(string(objectType), "_OBJ")
+}
+
+var ordinals = []string{"first", "second", "third", "fourth", "fifth", "sixth", "seventh", "eighth", "ninth", "tenth"}
+
+// position of argument i like "first", as in "argument to first"
+func ordinal(i int) string {
+	if i < len(ordinals) {
+		return ordinals[i]
+	}
+	n := i + 1
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return fmt.Sprintf("%dst", n)
+	case n%10 == 2 && n%100 != 12:
+		return fmt.Sprintf("%dnd", n)
+	case n%10 == 3 && n%100 != 13:
+		return fmt.Sprintf("%drd", n)
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+func typesString(types []ObjectType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = TypeName(t)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
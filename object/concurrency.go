@@ -0,0 +1,128 @@
+package object
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Task object is a function call running in its own goroutine
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+// start run in a new goroutine
+func NewTask(run func() Object) *Task {
+	task := &Task{done: make(chan struct{})}
+	go func() {
+		defer close(task.done)
+		task.result = run()
+	}()
+	return task
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string {
+	select {
+	case <-t.done:
+		return "<task done>"
+	default:
+		return "<task pending>"
+	}
+}
+
+// wait until the task finishes and return its result
+func (t *Task) Await() Object {
+	<-t.done
+	return t.result
+}
+
+// Channel object passes values between tasks
+type Channel struct {
+	values chan Object
+	// closed before values, to wake up blocked senders
+	done      chan struct{}
+	closeOnce sync.Once
+	// held for reading while sending, so that values is closed only once
+	// no send is in progress
+	mu     sync.RWMutex
+	closed bool
+}
+
+// create channel buffering up to size values
+func NewChannel(size int) *Channel {
+	return &Channel{values: make(chan Object, size), done: make(chan struct{})}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return "<channel>" }
+
+// iterate received values until the channel is closed
+//
+// the iterator is not a Closer, so that stopping the iteration early does
+// not close the channel
+func (c *Channel) Iterator() Iterator {
+	return &LazyIterator{NextFn: c.Recv}
+}
+
+// send value, blocking while the buffer is full
+//
+// returns false if the channel is closed, even while blocked
+func (c *Channel) Send(value Object) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return false
+	}
+	select {
+	case c.values <- value:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// receive value, blocking while the buffer is empty
+//
+// returns false if the channel is closed and drained
+func (c *Channel) Recv() (Object, bool) {
+	value, ok := <-c.values
+	return value, ok
+}
+
+// close channel, returns false if it is already closed
+func (c *Channel) Close() bool {
+	first := false
+	c.closeOnce.Do(func() {
+		first = true
+		close(c.done)
+	})
+	if !first {
+		return false
+	}
+
+	// wait for blocked senders to give up
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	close(c.values)
+	return true
+}
+
+// receive from whichever of channels is ready first
+//
+// returns the index of the channel, the value and false if that channel is
+// closed and drained
+func Select(channels []*Channel) (int, Object, bool) {
+	cases := make([]reflect.SelectCase, len(channels))
+	for index, channel := range channels {
+		cases[index] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.values)}
+	}
+
+	chosen, value, ok := reflect.Select(cases)
+	if !ok {
+		return chosen, nil, false
+	}
+	return chosen, value.Interface().(Object), true
+}
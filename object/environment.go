@@ -1,23 +1,93 @@
 package object
 
+import "sync"
+
+// Environment is safe for concurrent use by tasks sharing a closure
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu      sync.RWMutex
+	store   map[string]Object
+	outer   *Environment
+	yield   func(Object) bool
+	runtime *Runtime
+	source  string
 }
 
+// create top level environment of a new interpreter
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: &Runtime{}}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.runtime = outer.runtime
 	return env
 }
 
+// set source text of the code evaluated in the environment, which errors
+// raised there are located in
+func (e *Environment) SetSource(source string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.source = source
+}
+
+// source text of the code evaluated in the environment or the closest
+// enclosing one, empty if unknown
+func (e *Environment) Source() string {
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		source := env.source
+		env.mu.RUnlock()
+		if source != "" {
+			return source
+		}
+	}
+	return ""
+}
+
+// state of the interpreter the environment belongs to
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// Runtime is the state shared by all environments of one interpreter
+type Runtime struct {
+	// raise an error for out of range index or slice bounds instead of
+	// yielding null or clamping the bounds
+	StrictIndexing bool
+
+	mu sync.RWMutex
+	// methods attached to built-in types by the program
+	methods map[ObjectType]*MethodTable
+}
+
+// return methods attached to objectType by the program
+func (r *Runtime) Methods(objectType ObjectType) *MethodTable {
+	r.mu.RLock()
+	table, ok := r.methods[objectType]
+	r.mu.RUnlock()
+	if ok {
+		return table
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if table, ok = r.methods[objectType]; !ok {
+		if r.methods == nil {
+			r.methods = map[ObjectType]*MethodTable{}
+		}
+		table = &MethodTable{}
+		r.methods[objectType] = table
+	}
+	return table
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,6 +95,26 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
+
+// set yield callback of generator running in this environment
+func (e *Environment) SetYield(yield func(Object) bool) {
+	e.mu.Lock()
+	e.yield = yield
+	e.mu.Unlock()
+}
+
+// find yield callback of the innermost generator
+func (e *Environment) Yield() (func(Object) bool, bool) {
+	e.mu.RLock()
+	yield := e.yield
+	e.mu.RUnlock()
+	if yield == nil && e.outer != nil {
+		return e.outer.Yield()
+	}
+	return yield, yield != nil
+}
@@ -0,0 +1,240 @@
+package object
+
+import (
+	"math/big"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Iterable is implemented by objects which can be iterated by for statement
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// Iterator yields items of an iterable one by one
+type Iterator interface {
+	// return the next item, or false if the iterator is exhausted
+	Next() (Object, bool)
+}
+
+func (a *Array) Iterator() Iterator {
+	return &sliceIterator{items: a.Elements}
+}
+
+// yield [key, value] pairs ordered by key
+func (h *Hash) Iterator() Iterator {
+	pairs := h.SortedPairs()
+	items := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		items[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+	}
+	return &sliceIterator{items: items}
+}
+
+// yield characters of string
+func (s *String) Iterator() Iterator {
+	return &stringIterator{runes: []rune(s.Value)}
+}
+
+type sliceIterator struct {
+	items []Object
+	index int
+}
+
+func (si *sliceIterator) Next() (Object, bool) {
+	if si.index >= len(si.items) {
+		return nil, false
+	}
+	item := si.items[si.index]
+	si.index++
+	return item, true
+}
+
+type stringIterator struct {
+	runes []rune
+	index int
+}
+
+func (si *stringIterator) Next() (Object, bool) {
+	if si.index >= len(si.runes) {
+		return nil, false
+	}
+	item := &String{Value: string(si.runes[si.index])}
+	si.index++
+	return item, true
+}
+
+// return pairs of hash in a deterministic order
+//
+// keys are ordered by type first, then by value
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		left, right := pairs[i].Key, pairs[j].Key
+		if left.Type() != right.Type() {
+			return left.Type() < right.Type()
+		}
+		cmp, _ := Compare(left, right)
+		return cmp < 0
+	})
+	return pairs
+}
+
+// compare two objects of the same orderable type
+//
+// the second return value is false if the objects can't be ordered
+func Compare(a, b Object) (int, bool) {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, true
+			case a.Value > b.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+		if b, ok := b.(*BigInteger); ok {
+			return big.NewInt(a.Value).Cmp(b.Value), true
+		}
+	case *BigInteger:
+		if b, ok := ToBigInt(b); ok {
+			return a.Value.Cmp(b), true
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *Boolean:
+		if b, ok := b.(*Boolean); ok {
+			switch {
+			case a.Value == b.Value:
+				return 0, true
+			case b.Value:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// Closer is implemented by iterators holding resources which must be released
+// when the iteration stops before the iterator is exhausted
+type Closer interface {
+	Close()
+}
+
+// LazyIterator object yields items computed on demand by NextFn
+type LazyIterator struct {
+	NextFn  func() (Object, bool)
+	CloseFn func() // nil if there is nothing to release
+}
+
+func (li *LazyIterator) Type() ObjectType     { return ITERATOR_OBJ }
+func (li *LazyIterator) Inspect() string      { return "<iterator>" }
+func (li *LazyIterator) Iterator() Iterator   { return li }
+func (li *LazyIterator) Next() (Object, bool) { return li.NextFn() }
+func (li *LazyIterator) Close() {
+	if li.CloseFn != nil {
+		li.CloseFn()
+	}
+}
+
+// Generator object runs a function body which yields items one by one
+//
+// the body runs in its own goroutine, but control is handed over between the
+// body and the consumer so that only one of them runs at a time. the body is
+// stopped when the generator is closed, or dropped before it is exhausted
+type Generator struct {
+	// held by the goroutine, which must not keep the Generator reachable
+	state *generatorState
+}
+
+type generatorState struct {
+	run     func(yield func(Object) bool) Object
+	started bool
+	done    bool
+	items   chan Object
+	resume  chan bool
+}
+
+// create generator from run, which calls yield for each item and stops
+// early if yield returns false
+//
+// an error object returned from run is yielded as the last item
+func NewGenerator(run func(yield func(Object) bool) Object) *Generator {
+	g := &Generator{state: &generatorState{
+		run:    run,
+		items:  make(chan Object),
+		resume: make(chan bool),
+	}}
+	runtime.SetFinalizer(g, func(g *Generator) { g.state.close() })
+	return g
+}
+
+func (g *Generator) Type() ObjectType     { return ITERATOR_OBJ }
+func (g *Generator) Inspect() string      { return "<generator>" }
+func (g *Generator) Iterator() Iterator   { return g }
+func (g *Generator) Next() (Object, bool) { return g.state.next() }
+
+// stop the body of generator and wait until it returns
+func (g *Generator) Close() { g.state.close() }
+
+func (gs *generatorState) next() (Object, bool) {
+	if gs.done {
+		return nil, false
+	}
+
+	if gs.started {
+		gs.resume <- true
+	} else {
+		gs.started = true
+		go gs.start()
+	}
+
+	item, ok := <-gs.items
+	if !ok {
+		gs.done = true
+		return nil, false
+	}
+	return item, true
+}
+
+func (gs *generatorState) close() {
+	if gs.done {
+		return
+	}
+	gs.done = true
+	if !gs.started {
+		return
+	}
+
+	gs.resume <- false
+	for range gs.items {
+	}
+}
+
+func (gs *generatorState) start() {
+	defer close(gs.items)
+
+	stopped := false
+	result := gs.run(func(item Object) bool {
+		if stopped {
+			return false
+		}
+		gs.items <- item
+		stopped = !<-gs.resume
+		return !stopped
+	})
+	if !stopped && result != nil && result.Type() == ERROR_OBJ {
+		gs.items <- result
+		<-gs.resume
+	}
+}
@@ -0,0 +1,104 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MethodTable holds methods by name, called like "arr.push(1)"
+//
+// a method is a function or builtin taking its receiver as first argument.
+// the zero value is an empty table which is safe for concurrent use
+type MethodTable struct {
+	mu      sync.RWMutex
+	methods map[string]Object
+}
+
+// attach fn as method name, replacing any existing one
+func (mt *MethodTable) Set(name string, fn Object) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	if mt.methods == nil {
+		mt.methods = map[string]Object{}
+	}
+	mt.methods[name] = fn
+}
+
+func (mt *MethodTable) Get(name string) (Object, bool) {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	fn, ok := mt.methods[name]
+	return fn, ok
+}
+
+// return names of methods in sorted order
+func (mt *MethodTable) Names() []string {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	names := make([]string, 0, len(mt.methods))
+	for name := range mt.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// methods attached to object types by the host, shared by every interpreter
+//
+// methods attached by programs are kept by their struct type or Runtime
+var methods = struct {
+	sync.RWMutex
+	tables map[ObjectType]*MethodTable
+}{tables: map[ObjectType]*MethodTable{}}
+
+// attach fn to objectType as method name, replacing any existing one
+func RegisterMethod(objectType ObjectType, name string, fn Object) {
+	methods.Lock()
+	table, ok := methods.tables[objectType]
+	if !ok {
+		table = &MethodTable{}
+		methods.tables[objectType] = table
+	}
+	methods.Unlock()
+
+	table.Set(name, fn)
+}
+
+func LookupMethod(objectType ObjectType, name string) (Object, bool) {
+	methods.RLock()
+	table, ok := methods.tables[objectType]
+	methods.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	return table.Get(name)
+}
+
+// return names of methods of objectType in sorted order
+func MethodNames(objectType ObjectType) []string {
+	methods.RLock()
+	table, ok := methods.tables[objectType]
+	methods.RUnlock()
+
+	if !ok {
+		return []string{}
+	}
+	return table.Names()
+}
+
+// BoundMethod object is a method together with its receiver
+type BoundMethod struct {
+	Receiver Object
+	Name     string
+	Method   Object
+}
+
+func (bm *BoundMethod) Type() ObjectType { return METHOD_OBJ }
+func (bm *BoundMethod) Inspect() string {
+	return fmt.Sprintf("<method %s.%s>", bm.Receiver.Type(), bm.Name)
+}
@@ -23,6 +23,13 @@ const (
 	OBJECT_TYPE_OBJ  = "OBJECT_TYPE"
 	ARRAY_OBJ        = "ARRAY_OBJ"
 	HASH_OBJ         = "HASH_OBJ"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ITERATOR_OBJ     = "ITERATOR"
+	TASK_OBJ         = "TASK"
+	CHANNEL_OBJ      = "CHANNEL"
+	STRUCT_OBJ       = "STRUCT"
+	METHOD_OBJ       = "METHOD"
 )
 
 type Object interface {
@@ -96,9 +103,26 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break object, signals the enclosing loop to stop
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue object, signals the enclosing loop to skip to the next iteration
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 // ERROR object
 type Error struct {
 	Message string
+	// position of the node which raised the error, zero if unknown
+	Line   int
+	Column int
+	// source text the position refers to, empty if unknown
+	Source string
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -106,18 +130,20 @@ func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 // Function object
 type Function struct {
+	Name       string
+	Generator  bool // calling generator function returns Generator
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Source     string // source text containing Body, empty if unknown
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.ParameterStrings(f.Parameters, f.Defaults, f.Rest)
 	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -126,6 +152,21 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// minimum and maximum number of arguments
+//
+// max is -1 if the function has rest parameter
+func (f *Function) Arity() (min int, max int) {
+	for _, p := range f.Parameters {
+		if _, ok := f.Defaults[p.Value]; !ok {
+			min++
+		}
+	}
+	if f.Rest != nil {
+		return min, -1
+	}
+	return min, len(f.Parameters)
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -172,15 +213,34 @@ func (h *Hash) Inspect() string {
 
 // Built-in
 type Builtin struct {
-	Fn BuiltinFunction
+	Signature *BuiltinSignature
+	Fn        BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
-func (b *Builtin) Inspect() string  { return "built-in function" }
+func (b *Builtin) Inspect() string {
+	if b.Signature == nil {
+		return "built-in function"
+	}
+	return "built-in function " + b.Signature.String()
+}
+
+// validate args against the signature of builtin and call it
+//
+// builtins without a signature are called without validation
+func (b *Builtin) Call(args ...Object) Object {
+	if b.Signature != nil {
+		if err := b.Signature.Validate(args); err != nil {
+			return err
+		}
+	}
+	return b.Fn(args...)
+}
 
 // Built-in
 type ObjectTypeObject struct {
-	Value ObjectType
+	Value  ObjectType
+	Struct *StructType // declaration if Value is a struct type, nil otherwise
 }
 
 func (ot *ObjectTypeObject) Type() ObjectType { return OBJECT_TYPE_OBJ }
@@ -1,7 +1,10 @@
 package object
 
 import (
+	"math/big"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -17,3 +20,221 @@ func TestStringHashKey(t *testing.T) {
 	require.Equal(t, diff1.HashKey(), diff2.HashKey())
 	require.NotEqual(t, hello1.HashKey(), diff1.HashKey())
 }
+
+func TestBigIntegerHashKey(t *testing.T) {
+	small := &Integer{Value: 42}
+	big1 := &BigInteger{Value: big.NewInt(42)}
+	huge1 := NewInteger(new(big.Int).Lsh(big.NewInt(1), 100))
+	huge2 := NewInteger(new(big.Int).Lsh(big.NewInt(1), 100))
+	negative := NewInteger(new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 100)))
+
+	require.Equal(t, small.HashKey(), big1.HashKey())
+	require.Equal(t, huge1.(Hashable).HashKey(), huge2.(Hashable).HashKey())
+	require.NotEqual(t, huge1.(Hashable).HashKey(), negative.(Hashable).HashKey())
+	require.Equal(t, "1267650600228229401496703205376", huge1.Inspect())
+	require.IsType(t, &Integer{}, NewInteger(big.NewInt(-7)))
+}
+
+func TestBuiltinSignature(t *testing.T) {
+	signature := &BuiltinSignature{
+		Name: "sample",
+		Parameters: []BuiltinParameter{
+			{Name: "s", Types: []ObjectType{STRING_OBJ}},
+			{Name: "values", Types: []ObjectType{INTEGER_OBJ, BOOLEAN_OBJ}, Optional: true},
+		},
+		Variadic: true,
+	}
+
+	require.Equal(t, "sample(s: STRING, [...values: INTEGER | BOOLEAN])", signature.String())
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{&String{Value: "a"}}, ""},
+		{[]Object{&String{Value: "a"}, &Integer{Value: 1}, &Boolean{Value: true}}, ""},
+		{[]Object{}, "wrong number of arguments. got=0, want=>=1"},
+		{[]Object{&Integer{Value: 1}}, "argument to first must be STRING, got INTEGER"},
+		{[]Object{&String{Value: "a"}, &Integer{Value: 1}, &String{Value: "b"}}, "argument to third must be INTEGER or BOOLEAN, got STRING"},
+	}
+
+	for _, tt := range tests {
+		err := signature.Validate(tt.args)
+		if tt.expected == "" {
+			require.Nil(t, err)
+		} else {
+			require.Equal(t, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		iterable Iterable
+		expected []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, []string{"1", "a"}},
+		{&String{Value: "한글"}, []string{"한", "글"}},
+		{&Hash{Pairs: map[HashKey]HashPair{
+			(&String{Value: "b"}).HashKey(): {Key: &String{Value: "b"}, Value: &Integer{Value: 2}},
+			(&String{Value: "a"}).HashKey(): {Key: &String{Value: "a"}, Value: &Integer{Value: 1}},
+		}}, []string{"[a, 1]", "[b, 2]"}},
+	}
+
+	for _, tt := range tests {
+		items := []string{}
+		iterator := tt.iterable.Iterator()
+		for item, ok := iterator.Next(); ok; item, ok = iterator.Next() {
+			items = append(items, item.Inspect())
+		}
+		require.Equal(t, tt.expected, items)
+	}
+}
+
+func TestGeneratorClose(t *testing.T) {
+	finished := make(chan bool, 1)
+	generator := NewGenerator(func(yield func(Object) bool) Object {
+		defer func() { finished <- true }()
+		for i := int64(0); yield(&Integer{Value: i}); i++ {
+		}
+		return nil
+	})
+
+	first, ok := generator.Next()
+	require.True(t, ok)
+	require.Equal(t, "0", first.Inspect())
+	second, ok := generator.Next()
+	require.True(t, ok)
+	require.Equal(t, "1", second.Inspect())
+
+	generator.Close()
+	require.True(t, <-finished, "generator body is not finished after Close")
+
+	_, ok = generator.Next()
+	require.False(t, ok)
+}
+
+func TestGeneratorDropped(t *testing.T) {
+	finished := make(chan bool, 1)
+	func() {
+		generator := NewGenerator(func(yield func(Object) bool) Object {
+			defer func() { finished <- true }()
+			for i := int64(0); yield(&Integer{Value: i}); i++ {
+			}
+			return nil
+		})
+		_, ok := generator.Next()
+		require.True(t, ok)
+	}()
+
+	// the body stops once the generator is collected
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		select {
+		case <-finished:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatal("generator body is not finished after the generator is dropped")
+}
+
+func TestChannel(t *testing.T) {
+	channel := NewChannel(0)
+	task := NewTask(func() Object {
+		for i := int64(0); i < 3; i++ {
+			channel.Send(&Integer{Value: i})
+		}
+		channel.Close()
+		return &Integer{Value: 3}
+	})
+
+	var received []string
+	for iterator := channel.Iterator(); ; {
+		item, ok := iterator.Next()
+		if !ok {
+			break
+		}
+		received = append(received, item.Inspect())
+	}
+	require.Equal(t, []string{"0", "1", "2"}, received)
+	require.Equal(t, "3", task.Await().Inspect())
+	require.Equal(t, "<task done>", task.Inspect())
+
+	require.False(t, channel.Send(&Integer{Value: 4}), "send on closed channel succeeded")
+	require.False(t, channel.Close(), "channel closed twice")
+
+	index, _, ok := Select([]*Channel{NewChannel(0), channel})
+	require.Equal(t, 1, index)
+	require.False(t, ok)
+}
+
+func TestChannelCloseWhileSending(t *testing.T) {
+	channel := NewChannel(0)
+	sent := make(chan bool)
+	go func() { sent <- channel.Send(&Integer{Value: 1}) }()
+
+	require.True(t, channel.Close())
+	require.False(t, <-sent, "blocked send succeeded after close")
+	_, ok := channel.Recv()
+	require.False(t, ok)
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	env := NewEnclosedEnvironment(NewEnvironment())
+	tasks := make([]*Task, 8)
+	for i := range tasks {
+		value := &Integer{Value: int64(i)}
+		tasks[i] = NewTask(func() Object {
+			for j := 0; j < 100; j++ {
+				env.Set("x", value)
+				env.Get("x")
+				env.Get("missing")
+			}
+			return nil
+		})
+	}
+	for _, task := range tasks {
+		task.Await()
+	}
+
+	_, ok := env.Get("x")
+	require.True(t, ok)
+}
+
+func TestMethodTable(t *testing.T) {
+	double := &Builtin{Fn: func(args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	}}
+	RegisterMethod("TEST", "double", double)
+	RegisterMethod("TEST", "apply", double)
+
+	method, ok := LookupMethod("TEST", "double")
+	require.True(t, ok)
+	require.Equal(t, double, method)
+	_, ok = LookupMethod("TEST", "missing")
+	require.False(t, ok)
+	_, ok = LookupMethod("OTHER", "double")
+	require.False(t, ok)
+
+	require.Equal(t, []string{"apply", "double"}, MethodNames("TEST"))
+	require.Equal(t, []string{}, MethodNames("OTHER"))
+
+	var table MethodTable
+	table.Set("double", double)
+	require.Equal(t, []string{"double"}, table.Names())
+	_, ok = table.Get("apply")
+	require.False(t, ok)
+}
+
+func TestRuntimeMethods(t *testing.T) {
+	env := NewEnvironment()
+	env.Runtime().Methods("TEST").Set("double", &Boolean{Value: true})
+
+	enclosed := NewEnclosedEnvironment(env)
+	require.Same(t, env.Runtime(), enclosed.Runtime())
+	require.Equal(t, []string{"double"}, enclosed.Runtime().Methods("TEST").Names())
+
+	other := NewEnvironment()
+	require.Equal(t, []string{}, other.Runtime().Methods("TEST").Names())
+}
@@ -0,0 +1,103 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StructType object is a declared struct, which constructs its instances
+// when called
+type StructType struct {
+	Name   string
+	Fields []string
+	// methods of instances, attached to this declaration only
+	Methods MethodTable
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_OBJ }
+func (st *StructType) Inspect() string {
+	if len(st.Fields) == 0 {
+		return fmt.Sprintf("struct %s {}", st.Name)
+	}
+	return fmt.Sprintf("struct %s { %s }", st.Name, strings.Join(st.Fields, ", "))
+}
+
+// return index of field, or -1 if there is no such field
+func (st *StructType) FieldIndex(name string) int {
+	for index, field := range st.Fields {
+		if field == name {
+			return index
+		}
+	}
+	return -1
+}
+
+// Struct object is an instance of a declared struct
+//
+// fields are mutable, so access is guarded for tasks sharing the instance
+type Struct struct {
+	Definition *StructType
+	mu         sync.RWMutex
+	values     []Object // in the order of Definition.Fields
+}
+
+// create instance of st with values of its fields in order
+func NewStruct(st *StructType, values []Object) *Struct {
+	return &Struct{Definition: st, values: values}
+}
+
+// each struct is a type of its own named after the declaration
+func (s *Struct) Type() ObjectType { return ObjectType(s.Definition.Name) }
+func (s *Struct) Inspect() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fields := make([]string, len(s.values))
+	for index, value := range s.values {
+		fields[index] = fmt.Sprintf("%s: %s", s.Definition.Fields[index], value.Inspect())
+	}
+
+	var out bytes.Buffer
+	out.WriteString(s.Definition.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+func (s *Struct) Get(field string) (Object, bool) {
+	index := s.Definition.FieldIndex(field)
+	if index < 0 {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[index], true
+}
+
+// set value of field, returns false if there is no such field
+func (s *Struct) Set(field string, value Object) bool {
+	index := s.Definition.FieldIndex(field)
+	if index < 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[index] = value
+	return true
+}
+
+// whether name is taken by an object type other than structs
+func IsBuiltinType(name string) bool {
+	switch ObjectType(name) {
+	case INTEGER_OBJ, STRING_OBJ, BOOLEAN_OBJ, NULL_OBJ, RETURN_VALUE_OBJ, ERROR_OBJ,
+		FUNCTION_OBJ, BUILTIN_OBJ, OBJECT_TYPE_OBJ, ARRAY_OBJ, HASH_OBJ, BREAK_OBJ,
+		CONTINUE_OBJ, ITERATOR_OBJ, TASK_OBJ, CHANNEL_OBJ, STRUCT_OBJ, METHOD_OBJ:
+		return true
+	}
+	return false
+}
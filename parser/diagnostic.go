@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// problem found while parsing, located at 1-based line and column
+type Diagnostic struct {
+	Severity Severity
+	Line     int
+	Column   int
+	Message  string
+
+	// tokens which would have been accepted, if known
+	Expected []token.TokenType
+	// token actually found
+	Found token.TokenType
+	// human readable fix, empty if there is none
+	Suggestion string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// all diagnostics in the order they were found
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// record diagnostic unless it is a cascade of an error already reported
+func (p *Parser) report(diagnostic Diagnostic) {
+	if p.recovering && diagnostic.Severity == SeverityError {
+		return
+	}
+	for _, d := range p.diagnostics {
+		if d.Line == diagnostic.Line && d.Column == diagnostic.Column && d.Message == diagnostic.Message {
+			return
+		}
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostic)
+	if diagnostic.Severity == SeverityError {
+		p.errors = append(p.errors, diagnostic.Message)
+		p.recovering = true
+	}
+}
+
+// report error located at tok
+func (p *Parser) errorAt(tok token.Token, format string, a ...interface{}) {
+	p.report(Diagnostic{
+		Severity: SeverityError,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Message:  fmt.Sprintf(format, a...),
+		Found:    tok.Type,
+	})
+}
+
+// skip tokens of a broken statement, which started when start braces were
+// open, until a point where parsing can resume. it stops on a semicolon or
+// the brace closing the outermost brace opened by the statement, or before a
+// closing brace, EOF or the start of the next statement. it returns
+// true if it stopped on a closing brace which belongs to an enclosing block,
+// which the caller must not skip.
+func (p *Parser) synchronize(start int) bool {
+	p.recovering = false
+
+	// braces opened by the broken statement before its current token
+	open := p.braces - start
+
+	depth := 0
+	for !p.currentTokenIs(token.EOF) {
+		switch p.currentToken.Type {
+		case token.L_PAREN, token.L_BRACKET, token.L_BRACE:
+			depth++
+		case token.R_PAREN, token.R_BRACKET:
+			if depth > 0 {
+				depth--
+			}
+		case token.R_BRACE:
+			if depth > 0 {
+				depth--
+			} else if open > 0 {
+				// the statement ends with its outermost brace
+				if open--; open == 0 {
+					return false
+				}
+			} else if p.blocks > 0 {
+				return true
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return false
+			}
+		}
+
+		if depth == 0 && ((open == 0 && p.peekTokenIs(token.R_BRACE)) || p.peekTokenIs(token.EOF) || statementStarts[p.peekToken.Type]) {
+			return false
+		}
+		p.nextToken()
+	}
+	return false
+}
+
+// tokens which can only appear at the start of a statement
+var statementStarts = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.FOR:      true,
+	token.STRUCT:   true,
+	token.YIELD:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+// suggest fix for t missing after current
+func missingTokenSuggestion(t token.TokenType, current token.Token) string {
+	switch t {
+	case token.IDENTIFIER:
+		return fmt.Sprintf("add a name after %q", current.Literal)
+	case token.EOF:
+		return ""
+	default:
+		return fmt.Sprintf("insert %q after %q", token.Spelling(t), current.Literal)
+	}
+}
+
+// suggest fix for t found where an expression should start. unmatched
+// tells whether a closing t has no opening token before it
+func unexpectedTokenSuggestion(t token.TokenType, unmatched bool) string {
+	switch t {
+	case token.R_PAREN, token.R_BRACKET, token.R_BRACE:
+		if unmatched {
+			return fmt.Sprintf("remove the unmatched %q", string(t))
+		}
+		return fmt.Sprintf("add an expression before %q", string(t))
+	case token.ASSIGN:
+		return `use "==" to compare values`
+	case token.EOF:
+		return "the input ends before the expression is complete"
+	default:
+		return ""
+	}
+}
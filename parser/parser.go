@@ -1,16 +1,22 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	CONDITIONAL
+	PIPE
+	NULLISH
 	EQUALS
 	LESSGREATER
 	SUM
@@ -31,6 +37,20 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK:  PRODUCT,
 	token.L_PAREN:   CALL,
 	token.L_BRACKET: INDEX,
+	token.PIPE:      PIPE,
+	token.ARROW:     CALL,
+
+	// bitwise operators bind like in go
+	token.BIT_OR:      SUM,
+	token.BIT_XOR:     SUM,
+	token.BIT_AND:     PRODUCT,
+	token.SHIFT_LEFT:  PRODUCT,
+	token.SHIFT_RIGHT: PRODUCT,
+
+	token.QUESTION:       CONDITIONAL,
+	token.NULLISH:        NULLISH,
+	token.OPTIONAL_CHAIN: INDEX,
+	token.DOT:            INDEX,
 }
 
 type (
@@ -47,6 +67,20 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// whether each function literal being parsed contains yield, innermost last
+	yields []bool
+
+	diagnostics []Diagnostic
+	// set after an error until the parser synchronizes, to suppress cascades
+	recovering bool
+	// number of block statements being parsed
+	blocks int
+	// number of braces opened and not closed before currentToken
+	braces int
+	// same for parentheses and brackets
+	parens   int
+	brackets int
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -65,6 +99,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefixParseFn(token.STRING, p.parseStringLiteral)
 	p.registerPrefixParseFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixParseFn(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefixParseFn(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefixParseFn(token.TRUE, p.parseBoolean)
 	p.registerPrefixParseFn(token.FALSE, p.parseBoolean)
 	p.registerPrefixParseFn(token.L_PAREN, p.parseGroupedExpression)
@@ -72,6 +107,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefixParseFn(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefixParseFn(token.L_BRACKET, p.parseArrayLiteral)
 	p.registerPrefixParseFn(token.L_BRACE, p.parseHashLiteral)
+	p.registerPrefixParseFn(token.ELLIPSIS, p.parseSpreadExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfixParseFn(token.EQ, p.parseInfixExpression)
@@ -82,8 +118,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixParseFn(token.MINUS, p.parseInfixExpression)
 	p.registerInfixParseFn(token.SLASH, p.parseInfixExpression)
 	p.registerInfixParseFn(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfixParseFn(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfixParseFn(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfixParseFn(token.L_PAREN, p.parseCallExpression)
 	p.registerInfixParseFn(token.L_BRACKET, p.parseIndexExpression)
+	p.registerInfixParseFn(token.PIPE, p.parsePipeExpression)
+	p.registerInfixParseFn(token.ARROW, p.parseArrowFunction)
+	p.registerInfixParseFn(token.QUESTION, p.parseConditionalExpression)
+	p.registerInfixParseFn(token.NULLISH, p.parseInfixExpression)
+	p.registerInfixParseFn(token.OPTIONAL_CHAIN, p.parseOptionalChain)
+	p.registerInfixParseFn(token.DOT, p.parseMemberExpression)
 
 	return p
 }
@@ -97,7 +144,12 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.currentToken.Type != token.EOF {
-		if statement := p.parseStatement(); statement != nil {
+		start := p.braces
+		statement := p.parseStatement()
+		if p.recovering {
+			// statements containing errors are dropped
+			p.synchronize(start)
+		} else if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
 		p.nextToken()
@@ -115,8 +167,26 @@ func (p *Parser) registerInfixParseFn(tokenType token.TokenType, fn infixParseFn
 }
 
 func (p *Parser) nextToken() {
+	switch p.currentToken.Type {
+	case token.L_BRACE:
+		p.braces++
+	case token.R_BRACE:
+		p.braces--
+	case token.L_PAREN:
+		p.parens++
+	case token.R_PAREN:
+		p.parens--
+	case token.L_BRACKET:
+		p.brackets++
+	case token.R_BRACKET:
+		p.brackets--
+	}
 	p.currentToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	// comments are not part of the tree
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 // Parse function entrypoint
@@ -126,26 +196,55 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.BREAK:
+		statement := &ast.BreakStatement{Token: p.currentToken}
+		for p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return statement
+	case token.CONTINUE:
+		statement := &ast.ContinueStatement{Token: p.currentToken}
+		for p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return statement
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // Parse function for LET token
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
 	statement := &ast.LetStatement{Token: p.currentToken}
 
-	if !p.expectPeek(token.IDENTIFIER) {
-		return nil
+	if p.peekTokenIs(token.L_BRACKET) || p.peekTokenIs(token.L_BRACE) {
+		p.nextToken()
+		statement.Pattern = p.parsePattern()
+		if statement.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 	}
 
-	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 
 	statement.Value = p.parseExpression(LOWEST)
+	if fl, ok := statement.Value.(*ast.FunctionLiteral); ok && statement.Name != nil {
+		fl.Name = statement.Name.Value
+	}
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -153,7 +252,126 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return statement
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+// parse binding target of let statement starting from current token
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.currentToken.Type {
+	case token.IDENTIFIER:
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.L_BRACKET:
+		return p.parseArrayPattern()
+	case token.L_BRACE:
+		return p.parseHashPattern()
+	default:
+		p.errorAt(p.currentToken, "invalid destructuring target %s", p.currentToken.Type)
+		return nil
+	}
+}
+
+// parse binding target with optional default value like "x = 1"
+func (p *Parser) parsePatternElement() *ast.PatternElement {
+	target := p.parsePattern()
+	if target == nil {
+		return nil
+	}
+
+	element := &ast.PatternElement{Target: target}
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		p.nextToken()
+		element.Default = p.parseExpression(LOWEST)
+	}
+	return element
+}
+
+// parse rest binding of pattern like "...rest" and the closing token after it
+func (p *Parser) parsePatternRest(end token.TokenType) *ast.Identifier {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	rest := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return rest
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.R_BRACKET) {
+		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if pattern.Rest = p.parsePatternRest(token.R_BRACKET); pattern.Rest == nil {
+				return nil
+			}
+			return pattern
+		}
+
+		element := p.parsePatternElement()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.R_BRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.R_BRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.R_BRACE) {
+		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if pattern.Rest = p.parsePatternRest(token.R_BRACE); pattern.Rest == nil {
+				return nil
+			}
+			return pattern
+		}
+
+		if !p.currentTokenIs(token.IDENTIFIER) && !p.currentTokenIs(token.STRING) {
+			p.errorAt(p.currentToken, "invalid destructuring key %s", p.currentToken.Type)
+			return nil
+		}
+		pair := &ast.HashPatternPair{
+			Key: &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal},
+		}
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			pair.Value = p.parsePatternElement()
+		} else if p.currentTokenIs(token.IDENTIFIER) {
+			pair.Value = p.parsePatternElement()
+		} else {
+			p.peekError(token.COLON)
+		}
+		if pair.Value == nil {
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if !p.peekTokenIs(token.R_BRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.R_BRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseReturnStatement() ast.Statement {
 	statement := &ast.ReturnStatement{Token: p.currentToken}
 
 	p.nextToken()
@@ -166,10 +384,145 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseYieldStatement() ast.Statement {
+	statement := &ast.YieldStatement{Token: p.currentToken}
+	if len(p.yields) == 0 {
+		p.errorAt(p.currentToken, "yield outside of function")
+		return nil
+	}
+	p.yields[len(p.yields)-1] = true
+
+	if !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.R_BRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+		statement.Value = p.parseExpression(LOWEST)
+	}
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+// parse body of function literal, marking it as generator if it yields
+func (p *Parser) parseFunctionBody(fl *ast.FunctionLiteral) {
+	p.yields = append(p.yields, false)
+	fl.Body = p.parseBlockStatement()
+	fl.Generator = p.yields[len(p.yields)-1]
+	p.yields = p.yields[:len(p.yields)-1]
+}
+
+// parse "for (x in iterable) { ... }" or "for (k, v in iterable) { ... }"
+func (p *Parser) parseForStatement() ast.Statement {
+	statement := &ast.ForStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_PAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.R_PAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+	statement.Body = p.parseBlockStatement()
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+// parse struct declaration like "struct Point { x, y }"
+func (p *Parser) parseStructStatement() ast.Statement {
+	statement := &ast.StructStatement{Token: p.currentToken, Fields: []*ast.Identifier{}}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.R_BRACE) {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		for _, other := range statement.Fields {
+			if other.Value == field.Value {
+				p.errorAt(p.currentToken, "duplicate field %s in struct %s", field.Value, statement.Name.Value)
+				return nil
+			}
+		}
+		statement.Fields = append(statement.Fields, field)
+
+		if !p.peekTokenIs(token.R_BRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+// parse expression statement, or assignment to a field like "p.x = 1"
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	statement := &ast.ExpressionStatement{Token: p.currentToken}
 	statement.Expression = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.ASSIGN) {
+		return p.parseAssignStatement(statement.Expression)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	member, ok := target.(*ast.MemberExpression)
+	if !ok || member.Optional {
+		p.report(Diagnostic{
+			Severity:   SeverityError,
+			Line:       p.peekToken.Line,
+			Column:     p.peekToken.Column,
+			Message:    fmt.Sprintf("cannot assign to %s", target),
+			Suggestion: "only fields like p.x can be assigned; use let to bind a name",
+		})
+		return nil
+	}
+
+	p.nextToken()
+	statement := &ast.AssignStatement{Token: p.currentToken, Target: member}
+	p.nextToken()
+	statement.Value = p.parseExpression(LOWEST)
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -185,8 +538,13 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: p.currentToken}
 
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.currentToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.currentToken, Value: value}
+		}
+	}
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal))
+		p.errorAt(p.currentToken, "could not parse %q as integer", p.currentToken.Literal)
 		return nil
 	}
 
@@ -195,7 +553,107 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !strings.Contains(p.currentToken.Literal, "${") {
+		return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+	}
+	return p.parseInterpolatedString()
+}
+
+// split string literal into literal texts and "${...}" expressions
+//
+// "\${" is kept as literal "${"
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.currentToken}
+	literal := p.currentToken.Literal
+
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			value := text.String()
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(literal); i++ {
+		if strings.HasPrefix(literal[i:], "\\${") {
+			text.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(literal[i:], "${") {
+			text.WriteByte(literal[i])
+			continue
+		}
+
+		end := findInterpolationEnd(literal, i+2)
+		if end < 0 {
+			p.errorAt(p.currentToken, "unterminated interpolation in %q", literal)
+			return nil
+		}
+		source := literal[i+2 : end]
+		if strings.TrimSpace(source) == "" {
+			p.errorAt(p.currentToken, "empty interpolation in %q", literal)
+			return nil
+		}
+
+		flushText()
+		line, column := interpolationPosition(p.currentToken, literal[:i+2])
+		inner := New(lexer.NewAt(source, line, column))
+		expression := inner.parseExpression(LOWEST)
+		if !inner.peekTokenIs(token.EOF) {
+			inner.errorAt(inner.peekToken, "unexpected %s in interpolation %q", inner.peekToken.Type, source)
+		}
+		if len(inner.diagnostics) != 0 {
+			for _, diagnostic := range inner.diagnostics {
+				p.report(diagnostic)
+			}
+			return nil
+		}
+		str.Parts = append(str.Parts, expression)
+		i = end
+	}
+	flushText()
+
+	return str
+}
+
+// line and column of the source following prefix of the literal of string
+// token tok, which starts after the opening quote
+func interpolationPosition(tok token.Token, prefix string) (int, int) {
+	line, column := tok.Line, tok.Column+1
+	for _, ch := range prefix {
+		if ch == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// find index of '}' closing the interpolation which starts at start
+//
+// return -1 if the interpolation is not closed
+func findInterpolationEnd(literal string, start int) int {
+	depth := 1
+	inner := false
+	for i := start; i < len(literal); i++ {
+		switch ch := literal[i]; {
+		case ch == '"':
+			inner = !inner
+		case inner:
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -236,9 +694,18 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
 	block.Statements = []ast.Statement{}
 
+	p.blocks++
+	defer func() { p.blocks-- }()
+
 	p.nextToken()
 	for !p.currentTokenIs(token.R_BRACE) && !p.currentTokenIs(token.EOF) {
-		if statement := p.parseStatement(); statement != nil {
+		start := p.braces
+		statement := p.parseStatement()
+		if p.recovering {
+			if p.synchronize(start) {
+				break
+			}
+		} else if statement != nil {
 			block.Statements = append(block.Statements, statement)
 		}
 		p.nextToken()
@@ -255,12 +722,14 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.L_PAREN) {
 		return nil
 	}
-	fl.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fl) {
+		return nil
+	}
 
 	if !p.expectPeek(token.L_BRACE) {
 		return nil
 	}
-	fl.Body = p.parseBlockStatement()
+	p.parseFunctionBody(fl)
 
 	return fl
 }
@@ -300,28 +769,51 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parse parameters of function literal like (x, y = 10, ...rest)
+//
+// return false if parameters are malformed
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	fl.Parameters = []*ast.Identifier{}
 	if p.peekTokenIs(token.R_PAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
 	for {
-		p.nextToken()
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+			fl.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
+		fl.Parameters = append(fl.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if fl.Defaults == nil {
+				fl.Defaults = make(map[string]ast.Expression)
+			}
+			fl.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(fl.Defaults) > 0 {
+			p.requiredAfterDefaultError(ident)
+			return false
+		}
+
 		if !p.peekTokenIs(token.COMMA) {
 			break
 		}
 		p.nextToken()
 	}
 
-	if !p.expectPeek(token.R_PAREN) {
-		return nil
-	}
-
-	return identifiers
+	return p.expectPeek(token.R_PAREN)
 }
 
 // Pratt parser
@@ -333,7 +825,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 	leftExpression := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedences() {
+	for !p.recovering && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedences() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExpression
@@ -356,14 +848,117 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.peekTokenIs(token.R_PAREN) {
+		// "()" is only valid as parameters of arrow function
+		p.nextToken()
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		return p.parseArrowFunctionBody(&ast.FunctionLiteral{Token: p.currentToken, Parameters: []*ast.Identifier{}})
+	}
+
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.ASSIGN) {
+		return p.parseArrowFunctionParameters(exp)
+	}
 	if !p.expectPeek(token.R_PAREN) {
 		return nil
 	}
 	return exp
 }
 
+// parse the rest of parameters of arrow function like "(a, b = 1, ...rest) => a"
+// after its first parameter
+func (p *Parser) parseArrowFunctionParameters(first ast.Expression) ast.Expression {
+	fl := &ast.FunctionLiteral{Parameters: []*ast.Identifier{}}
+
+	exp := first
+	for {
+		if !p.addArrowFunctionParameter(fl, exp) {
+			return nil
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+		p.nextToken()
+		exp = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.R_PAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	fl.Token = p.currentToken
+	return p.parseArrowFunctionBody(fl)
+}
+
+// add exp, which is parsed as an expression, as a parameter of fl
+func (p *Parser) addArrowFunctionParameter(fl *ast.FunctionLiteral, exp ast.Expression) bool {
+	if fl.Rest != nil {
+		p.errorAt(p.currentToken, "rest parameter must be last")
+		return false
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		fl.Parameters = append(fl.Parameters, exp)
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if fl.Defaults == nil {
+				fl.Defaults = make(map[string]ast.Expression)
+			}
+			fl.Defaults[exp.Value] = p.parseExpression(LOWEST)
+		} else if len(fl.Defaults) > 0 {
+			p.requiredAfterDefaultError(exp)
+			return false
+		}
+		return true
+	case *ast.SpreadExpression:
+		if rest, ok := exp.Value.(*ast.Identifier); ok {
+			fl.Rest = rest
+			return true
+		}
+	}
+
+	if exp != nil {
+		p.errorAt(p.currentToken, "invalid arrow function parameter %s", exp.String())
+	}
+	return false
+}
+
+// arguments fill parameters in order, so defaults can only be trailing
+func (p *Parser) requiredAfterDefaultError(parameter *ast.Identifier) {
+	p.errorAt(parameter.Token, "parameter %s without default must not follow parameters with defaults", parameter.Value)
+}
+
+// parse arrow function with single parameter like "x => x * 2"
+func (p *Parser) parseArrowFunction(left ast.Expression) ast.Expression {
+	fl := &ast.FunctionLiteral{Token: p.currentToken, Parameters: []*ast.Identifier{}}
+	if !p.addArrowFunctionParameter(fl, left) {
+		return nil
+	}
+	return p.parseArrowFunctionBody(fl)
+}
+
+// parse body of arrow function after "=>", either a block or an expression
+func (p *Parser) parseArrowFunctionBody(fl *ast.FunctionLiteral) ast.Expression {
+	p.nextToken()
+	if p.currentTokenIs(token.L_BRACE) {
+		p.parseFunctionBody(fl)
+		return fl
+	}
+
+	statement := &ast.ExpressionStatement{Token: p.currentToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	fl.Body = &ast.BlockStatement{Token: fl.Token, Statements: []ast.Statement{statement}}
+	return fl
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.currentToken,
@@ -378,18 +973,119 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	expression := &ast.ConditionalExpression{Token: p.currentToken, Condition: condition}
+
+	p.nextToken()
+	expression.Consequence = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// alternative is parsed with LOWEST to be right-associative
+	p.nextToken()
+	expression.Alternative = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+// parse "?.[...]" or "?.(...)" after left
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	switch {
+	case p.peekTokenIs(token.L_BRACKET):
+		p.nextToken()
+		switch expression := p.parseIndexExpression(left).(type) {
+		case *ast.IndexExpression:
+			expression.Optional = true
+			return expression
+		case *ast.SliceExpression:
+			expression.Optional = true
+			return expression
+		}
+		return nil
+	case p.peekTokenIs(token.L_PAREN):
+		p.nextToken()
+		expression := p.parseCallExpression(left).(*ast.CallExpression)
+		expression.Optional = true
+		return expression
+	case p.peekTokenIs(token.IDENTIFIER):
+		expression := p.parseMemberExpression(left).(*ast.MemberExpression)
+		expression.Optional = true
+		return expression
+	default:
+		p.report(Diagnostic{
+			Severity: SeverityError,
+			Line:     p.peekToken.Line,
+			Column:   p.peekToken.Column,
+			Message:  fmt.Sprintf("expected next token to be [, ( or identifier after ?., got %s instead", p.peekToken.Type),
+			Expected: []token.TokenType{token.L_BRACKET, token.L_PAREN, token.IDENTIFIER},
+			Found:    p.peekToken.Type,
+		})
+		return nil
+	}
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	expression := &ast.MemberExpression{Token: p.currentToken, Object: object}
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	expression.Member = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	return expression
+}
+
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.PipeExpression{Token: p.currentToken, Left: left}
+
+	precedences := p.currentPrecedences()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedences)
+
+	return expression
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: p.currentToken, Function: function}
 	expression.Arguments = p.parseExpressionList(token.R_PAREN)
 	return expression
 }
 
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	expression := &ast.SpreadExpression{Token: p.currentToken}
+	p.nextToken()
+	expression.Value = p.parseExpression(PREFIX)
+	return expression
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	expression := &ast.IndexExpression{Token: p.currentToken, Left: left}
 
 	p.nextToken()
+	if p.currentTokenIs(token.COLON) {
+		return p.parseSliceExpression(expression.Token, left, nil)
+	}
 	expression.Index = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(expression.Token, left, expression.Index)
+	}
+	if !p.expectPeek(token.R_BRACKET) {
+		return nil
+	}
+
+	return expression
+}
+
+// parse the rest of slice expression after ':'
+func (p *Parser) parseSliceExpression(tok token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	expression := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	if !p.peekTokenIs(token.R_BRACKET) {
+		p.nextToken()
+		expression.End = p.parseExpression(LOWEST)
+	}
+
 	if !p.expectPeek(token.R_BRACKET) {
 		return nil
 	}
@@ -444,6 +1140,14 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// binding power of infix operator t, LOWEST if t is not an operator
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 // find precedence
 func (p *Parser) peekPrecedences() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
@@ -460,9 +1164,38 @@ func (p *Parser) currentPrecedences() int {
 
 // add error for token
 func (p *Parser) peekError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type))
+	p.report(Diagnostic{
+		Severity:   SeverityError,
+		Line:       p.peekToken.Line,
+		Column:     p.peekToken.Column,
+		Message:    fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
+		Expected:   []token.TokenType{t},
+		Found:      p.peekToken.Type,
+		Suggestion: missingTokenSuggestion(t, p.currentToken),
+	})
+}
+
+// report whether closing token t has no opening token before it
+func (p *Parser) unmatched(t token.TokenType) bool {
+	switch t {
+	case token.R_PAREN:
+		return p.parens <= 0
+	case token.R_BRACKET:
+		return p.brackets <= 0
+	case token.R_BRACE:
+		return p.braces <= 0
+	default:
+		return false
+	}
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t))
+	p.report(Diagnostic{
+		Severity:   SeverityError,
+		Line:       p.currentToken.Line,
+		Column:     p.currentToken.Column,
+		Message:    fmt.Sprintf("no prefix parse function for %s found", t),
+		Found:      t,
+		Suggestion: unexpectedTokenSuggestion(t, p.unmatched(t)),
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"log"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -35,6 +36,53 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [a, b = 1, ...rest] = arr;", "let [a, b = 1, ...rest] = arr;"},
+		{"let [] = arr;", "let [] = arr;"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;"},
+		{`let {"first-name": first = "x", ...others} = person;`, "let {first-name: first = x, ...others} = person;"},
+		{"let {pos: [x, y], meta: {tag}} = item;", "let {pos: [x, y], meta: {tag}} = item;"},
+		{"let [{id}, [c]] = items;", "let [{id}, [c]] = items;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, 1, len(program.Statements), "program.Statements does not contain 1 statement.")
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		require.True(t, ok, "statement is not let statement")
+		require.NotNil(t, statement.Pattern, "let statement has no pattern")
+		require.Equal(t, tt.expected, statement.String())
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [1] = arr;", "invalid destructuring target INT"},
+		{"let {1: a} = h;", "invalid destructuring key INT"},
+		{`let {"a"} = h;`, "expected next token to be :, got } instead"},
+		{"let [...rest, a] = arr;", "expected next token to be ], got , instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, tt.expected, p.Errors()[0])
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	tests := []struct {
 		input               string
@@ -87,6 +135,62 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, 5, statement.Expression)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+	require.Equal(t, 1, len(program.Statements), "statement does not contain 1 statements, %s", program.Statements)
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+	literal, ok := statement.Expression.(*ast.BigIntegerLiteral)
+	require.True(t, ok, "expression is not BigIntegerLiteral, %T", statement.Expression)
+	require.Equal(t, "123456789012345678901234567890", literal.Value.String())
+	require.Equal(t, "123456789012345678901234567890", literal.String())
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1_000_000", 1000000},
+		{"0xff", 255},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"0b_1010_1010", 170},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		integer, ok := statement.Expression.(*ast.IntegerLiteral)
+		require.True(t, ok, "Expression is not integer literal, %s", statement.Expression)
+		require.Equal(t, tt.expected, integer.Value, "Wrong value of %s", tt.input)
+		require.Equal(t, tt.input, integer.String())
+	}
+
+	for _, input := range []string{"0b12", "1__0", "0x", "12abc"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		require.Equal(t, []string{fmt.Sprintf("could not parse %q as integer", input)}, p.Errors())
+	}
+
+	p := New(lexer.New("0xffffffffffffffffffff"))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+	literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.BigIntegerLiteral)
+	require.Equal(t, "1208925819614629174706175", literal.Value.String())
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world!";`
 
@@ -101,6 +205,43 @@ func TestStringLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, "hello world!", statement.Expression)
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"a ${b + 1} c ${d}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	require.True(t, ok, "Expression is not InterpolatedString, %s", stmt.Expression)
+	require.Equal(t, 4, len(str.Parts), "wrong number of parts")
+
+	testStringLiteral(t, "a ", str.Parts[0])
+	testInfixExpression(t, "b", "+", 1, str.Parts[1])
+	testStringLiteral(t, " c ", str.Parts[2])
+	testIdentifier(t, "d", str.Parts[3])
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${}"`, `empty interpolation in "a ${}"`},
+		{`"a ${b c}"`, `unexpected IDENTIFIER in interpolation "b c"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, []string{tt.expected}, p.Errors())
+	}
+}
+
 func TestBooleanLiteralExpression(t *testing.T) {
 	input := "true;"
 
@@ -207,6 +348,37 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b*c) +d", "((a + add((b * c))) + d)"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1,2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a |> f(b) |> g", "((a |> f(b)) |> g)"},
+		{"a + b |> f == c", "((a + b) |> (f == c))"},
+		{"arr |> map(x => x * 2)", "(arr |> map((x) => (x * 2)))"},
+		{"(a, b) => a + b", "(a, b) => (a + b)"},
+		{"(a, b = 1, ...rest) => a", "(a, b = 1, ...rest) => a"},
+		{"(x) => x", "(x) => x"},
+		{"(...args) => args", "(...args) => args"},
+		{"() => { return 1; }", "() => return 1;"},
+		{"x => y => x + y", "(x) => (y) => (x + y)"},
+		{"a ? b : c", "(a ? b : c)"},
+		{"a == 1 ? b + 1 : c * 2", "((a == 1) ? (b + 1) : (c * 2))"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{`h?.["k"]?.[0]`, "((h?.[k])?.[0])"},
+		{"f?.(1, 2)", "f?.(1, 2)"},
+		{"arr[c ? 0 : 1]", "(arr[(c ? 0 : 1)])"},
+		{"s?.[1:]", "(s?.[1:])"},
+		{"p.x", "(p.x)"},
+		{"p.x.y + 1", "(((p.x).y) + 1)"},
+		{"-p.x", "(-(p.x))"},
+		{"arr[0].x", "((arr[0]).x)"},
+		{"f(a).b", "(f(a).b)"},
+		{"p?.x?.y", "((p?.x)?.y)"},
+		{"a | b & c", "(a | (b & c))"},
+		{"a ^ b << 2", "(a ^ (b << 2))"},
+		{"a & 1 == 0", "((a & 1) == 0)"},
+		{"~a + b", "((~a) + b)"},
+		{"a >> 1 * 2", "((a >> 1) * 2)"},
 	}
 	for _, precedenceTest := range precedenceTests {
 		l := lexer.New(precedenceTest.input)
@@ -219,6 +391,152 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestConditionalExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ? b", "expected next token to be :, got EOF instead"},
+		{"a?.1", "expected next token to be [, ( or identifier after ?., got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, tt.expected, p.Errors()[0])
+	}
+}
+
+func TestArrowFunctionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a, 1) => a", "invalid arrow function parameter 1"},
+		{"(a, b)", "expected next token to be =>, got EOF instead"},
+		{"1 => 2", "invalid arrow function parameter 1"},
+		{"(...a, b) => a", "rest parameter must be last"},
+		{"(a = 1, b) => b", "parameter b without default must not follow parameters with defaults"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, tt.expected, p.Errors()[0])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in arr) { puts(x); }", "for (x in arr) puts(x)"},
+		{"for (k, v in h) { break; }", "for (k, v in h) break;"},
+		{"for (i in range(0, 10)) { continue; }", "for (i in range(0, 10)) continue;"},
+		{"for (x in [1]) { puts(x) };", "for (x in [1]) puts(x)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, 1, len(program.Statements), "program.Statements does not contain 1 statement.")
+		_, ok := program.Statements[0].(*ast.ForStatement)
+		require.True(t, ok, "statement is not for statement, %s", program.Statements[0])
+		require.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Point { x, y, };", "struct Point { x, y }"},
+		{"struct Empty {}", "struct Empty {}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, 1, len(program.Statements), "program.Statements does not contain 1 statement.")
+		_, ok := program.Statements[0].(*ast.StructStatement)
+		require.True(t, ok, "statement is not struct statement, %s", program.Statements[0])
+		require.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestAssignStatement(t *testing.T) {
+	l := lexer.New("p.x = y + 1; p.x.y = 2")
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	require.Equal(t, 2, len(program.Statements), "program.Statements does not contain 2 statements.")
+	_, ok := program.Statements[0].(*ast.AssignStatement)
+	require.True(t, ok, "statement is not assign statement, %s", program.Statements[0])
+	require.Equal(t, "(p.x) = (y + 1);((p.x).y) = 2;", program.String())
+}
+
+func TestStructAndAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { x }", "expected next token to be IDENTIFIER, got { instead"},
+		{"struct Point { x y }", "expected next token to be ,, got IDENTIFIER instead"},
+		{"struct Point { x, x }", "duplicate field x in struct Point"},
+		{"p.1", "expected next token to be IDENTIFIER, got INT instead"},
+		{"x = 1", "cannot assign to x"},
+		{"p?.x = 1", "cannot assign to (p?.x)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.NotEmpty(t, p.Errors(), "no errors for %s", tt.input)
+		require.Equal(t, tt.expected, p.Errors()[0])
+	}
+}
+
+func TestGeneratorFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input     string
+		generator bool
+	}{
+		{"fn() { yield 1; }", true},
+		{"fn() { if (true) { yield; } }", true},
+		{"() => { yield 1; }", true},
+		{"fn() { return 1; }", false},
+		{"fn() { fn() { yield 1; } }", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		function := statement.Expression.(*ast.FunctionLiteral)
+		require.Equal(t, tt.generator, function.Generator, "wrong generator flag for %s", tt.input)
+	}
+
+	p := New(lexer.New("yield 1;"))
+	p.ParseProgram()
+	require.Equal(t, []string{"yield outside of function"}, p.Errors())
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if(x<y){x}`
 
@@ -317,6 +635,68 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y = 10){};", "fn(x, y = 10)"},
+		{"fn(first, ...rest){};", "fn(first, ...rest)"},
+		{"fn(x = 1 + 2, ...rest){};", "fn(x = (1 + 2), ...rest)"},
+		{"fn(...args){};", "fn(...args)"},
+		{"let add = fn(x, y) { x + y };", "let add = fn(x, y)(x + y);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, tt.expected, program.String())
+	}
+
+	program := New(lexer.New("let add = fn(x, y) { x + y };")).ParseProgram()
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	require.Equal(t, "add", function.Name, "function literal is not named after let binding")
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(1){};", "expected next token to be IDENTIFIER, got INT instead"},
+		{"fn(...rest, x){};", "expected next token to be ), got , instead"},
+		{"fn(a = 1, b){ b };", "parameter b without default must not follow parameters with defaults"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, tt.expected, p.Errors()[0])
+	}
+}
+
+func TestCallArgumentsParsing(t *testing.T) {
+	input := "add(1, ...rest);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.CallExpression)
+	require.True(t, ok, "Expression is not CallExpression, %s", expression)
+	require.Equal(t, 2, len(expression.Arguments), "len(Arguments) != 2, %s", expression.Arguments)
+
+	testLiteralExpression(t, 1, expression.Arguments[0])
+	spread, ok := expression.Arguments[1].(*ast.SpreadExpression)
+	require.True(t, ok, "Arguments[1] is not SpreadExpression, %s", expression.Arguments[1])
+	testIdentifier(t, "rest", spread.Value)
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2*3, 4+5);"
 
@@ -372,6 +752,52 @@ func TestParsingIndexExpression(t *testing.T) {
 	testInfixExpression(t, 1, "+", 1, indexExp.Index)
 }
 
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		start    interface{}
+		end      interface{}
+	}{
+		{"myArray[1:3]", "(myArray[1:3])", 1, 3},
+		{"myArray[:2]", "(myArray[:2])", nil, 2},
+		{"myArray[2:]", "(myArray[2:])", 2, nil},
+		{"myArray[:]", "(myArray[:])", nil, nil},
+		{"myArray[-2:a]", "(myArray[(-2):a])", -2, "a"},
+		{"myArray[-2:]", "(myArray[(-2):])", -2, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		require.True(t, ok, "Expression is not SliceExpression, %s", stmt.Expression)
+		require.Equal(t, tt.expected, sliceExp.String())
+
+		testIdentifier(t, "myArray", sliceExp.Left)
+		if start, ok := tt.start.(int); ok && start < 0 {
+			prefix, ok := sliceExp.Start.(*ast.PrefixExpression)
+			require.True(t, ok, "Start is not PrefixExpression, %s", sliceExp.Start)
+			require.Equal(t, "-", prefix.Operator)
+			testLiteralExpression(t, -start, prefix.Right)
+		} else if tt.start != nil {
+			testLiteralExpression(t, tt.start, sliceExp.Start)
+		} else {
+			require.Nil(t, sliceExp.Start)
+		}
+		if tt.end != nil {
+			testLiteralExpression(t, tt.end, sliceExp.End)
+		} else {
+			require.Nil(t, sliceExp.End)
+		}
+	}
+}
+
 func TestParsingHashLiterals(t *testing.T) {
 	testcases := []struct {
 		input    string
@@ -435,6 +861,276 @@ func TestParsingHashLiterals(t *testing.T) {
 
 // Helper functionss
 //
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1 + // inside
+  2; // trailing
+// last`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+	require.Len(t, program.Statements, 1)
+	require.Equal(t, "let x = (1 + 2);", program.String())
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"let x = (1 + 2; let y = 3;",
+			[]string{"expected next token to be ), got ; instead"},
+			[]string{"let y = 3;"},
+		},
+		{
+			"let = 1; let y 2; let z = 3;",
+			[]string{"expected next token to be IDENTIFIER, got = instead", "expected next token to be =, got INT instead"},
+			[]string{"let z = 3;"},
+		},
+		{
+			"let a = ); let b = 2",
+			[]string{"no prefix parse function for ) found"},
+			[]string{"let b = 2;"},
+		},
+		{
+			"let f = fn(x) { let = 1; x }; f(1)",
+			[]string{"expected next token to be IDENTIFIER, got = instead"},
+			[]string{"let f = fn(x)x;", "f(1)"},
+		},
+		{
+			"if (x) { 1 + } let y = 2;",
+			[]string{"no prefix parse function for } found"},
+			[]string{"ifx ", "let y = 2;"},
+		},
+		{
+			"foo(1, 2; bar(3)",
+			[]string{"expected next token to be ), got ; instead"},
+			[]string{"bar(3)"},
+		},
+		{
+			"struct P { x, x } let p = 1;",
+			[]string{"duplicate field x in struct P"},
+			[]string{"let p = 1;"},
+		},
+		{
+			"let f = fn() { struct P { x, x } 1 }; f()",
+			[]string{"duplicate field x in struct P"},
+			[]string{"let f = fn()1;", "f()"},
+		},
+		{
+			`let h = {"a": }; let y = 1;`,
+			[]string{"no prefix parse function for } found"},
+			[]string{"let y = 1;"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		require.Equal(t, tt.expectedErrors, p.Errors(), "wrong errors for %s", tt.input)
+
+		statements := []string{}
+		for _, statement := range program.Statements {
+			statements = append(statements, statement.String())
+		}
+		require.Equal(t, tt.expectedStatements, statements, "wrong statements for %s", tt.input)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Diagnostic
+	}{
+		{
+			"let x = (1 + 2;",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     15,
+				Message:    "expected next token to be ), got ; instead",
+				Expected:   []token.TokenType{token.R_PAREN},
+				Found:      token.SEMICOLON,
+				Suggestion: `insert ")" after "2"`,
+			},
+		},
+		{
+			"let x = 1;\nlet y = );",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       2,
+				Column:     9,
+				Message:    "no prefix parse function for ) found",
+				Found:      token.R_PAREN,
+				Suggestion: `remove the unmatched ")"`,
+			},
+		},
+		{
+			"if (x = 1) { x }",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     7,
+				Message:    "expected next token to be ), got = instead",
+				Expected:   []token.TokenType{token.R_PAREN},
+				Found:      token.ASSIGN,
+				Suggestion: `insert ")" after "x"`,
+			},
+		},
+		{
+			"let s = \"a ${1 +} b\";",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     17,
+				Message:    "no prefix parse function for EOF found",
+				Found:      token.EOF,
+				Suggestion: "the input ends before the expression is complete",
+			},
+		},
+		{
+			"let c = fn(x) { x + };",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     21,
+				Message:    "no prefix parse function for } found",
+				Found:      token.R_BRACE,
+				Suggestion: `add an expression before "}"`,
+			},
+		},
+		{
+			"let f = fn(a) { a }; }",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     22,
+				Message:    "no prefix parse function for } found",
+				Found:      token.R_BRACE,
+				Suggestion: `remove the unmatched "}"`,
+			},
+		},
+		{
+			"for (x [1]) { x }",
+			Diagnostic{
+				Severity:   SeverityError,
+				Line:       1,
+				Column:     8,
+				Message:    "expected next token to be IN, got [ instead",
+				Expected:   []token.TokenType{token.IN},
+				Found:      token.L_BRACKET,
+				Suggestion: `insert "in" after "x"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		require.Equal(t, []Diagnostic{tt.expected}, p.Diagnostics(), "wrong diagnostics for %s", tt.input)
+	}
+
+	d := Diagnostic{Severity: SeverityError, Line: 3, Column: 7, Message: "oops"}
+	require.Equal(t, "3:7: error: oops", d.String())
+}
+
+func TestInspectVisitsEveryNode(t *testing.T) {
+	input := `
+	struct P { x, y }
+	let [a, b = 1, ...rest] = [1, 2];
+	let {c, d: e = 2, ...others} = {"c": 1};
+	let f = fn(x, y = 3, ...z) {
+		for (k, v in z) {
+			if (!k) { break } else { continue }
+		}
+		yield x;
+		return y;
+	};
+	let p = P(1, 2);
+	p.x = 99999999999999999999;
+	[1, ...rest][0][1:2];
+	"${a}" |> f(true, false ? p?.x : -1 + 2);
+	(v) => v;
+	`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	visited := map[string]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			visited[fmt.Sprintf("%T", node)] = true
+		}
+		return true
+	})
+
+	for _, expected := range []string{
+		"*ast.Program", "*ast.ExpressionStatement", "*ast.BlockStatement", "*ast.LetStatement",
+		"*ast.ReturnStatement", "*ast.ForStatement", "*ast.BreakStatement", "*ast.ContinueStatement",
+		"*ast.YieldStatement", "*ast.StructStatement", "*ast.AssignStatement", "*ast.Identifier",
+		"*ast.IntegerLiteral", "*ast.BigIntegerLiteral", "*ast.StringLiteral", "*ast.Boolean",
+		"*ast.PrefixExpression", "*ast.InfixExpression", "*ast.IfExpression", "*ast.FunctionLiteral",
+		"*ast.CallExpression", "*ast.ArrayLiteral", "*ast.IndexExpression", "*ast.MemberExpression",
+		"*ast.HashLiteral", "*ast.SliceExpression", "*ast.InterpolatedString", "*ast.SpreadExpression",
+		"*ast.ArrayPattern", "*ast.HashPattern", "*ast.PipeExpression", "*ast.ConditionalExpression",
+	} {
+		require.True(t, visited[expected], "%s not visited", expected)
+	}
+}
+
+func TestInterpolationPositions(t *testing.T) {
+	input := "let s = \"a\n ${x + 1}\";"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	str := program.Statements[0].(*ast.LetStatement).Value.(*ast.InterpolatedString)
+	infix := str.Parts[1].(*ast.InfixExpression)
+	require.Equal(t, token.Token{Type: token.PLUS, Literal: "+", Line: 2, Column: 6}, infix.Token)
+	require.Equal(t, token.Token{Type: token.IDENTIFIER, Literal: "x", Line: 2, Column: 4}, infix.Left.(*ast.Identifier).Token)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `
+	struct P { x, y }
+	let [a, b = 1, ...rest] = [1, 2];
+	let {c, d: e = 2, ...others} = {"c": 1, "d": [3], 4: fn() {}};
+	let f = fn(x, y = 3, ...z) {
+		for (k, v in z) {
+			if (!k) { break } else { continue }
+		}
+		yield x;
+		return y;
+	};
+	let p = P(1, 2);
+	p.x = 99999999999999999999;
+	[1, ...rest][0][1:2];
+	"${a}" |> f(true, false ? p?.x : -1 + 2);
+	(v) => v;
+	`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	data, err := ast.MarshalJSON(program)
+	require.NoError(t, err)
+	decoded, err := ast.UnmarshalJSON(data)
+	require.NoError(t, err)
+
+	// String of hash literals is unordered, encoding is in source order
+	again, err := ast.MarshalJSON(decoded)
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(again))
+}
+
 func testParserErrors(t *testing.T, p *Parser) {
 	if len(p.Errors()) != 0 {
 		log.Printf("parser has %d erros", len(p.Errors()))
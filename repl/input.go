@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+)
+
+// tokens which cannot end a statement, so more input must follow them
+var continuedBy = map[token.TokenType]bool{
+	token.ASSIGN:         true,
+	token.PLUS:           true,
+	token.MINUS:          true,
+	token.BANG:           true,
+	token.ASTERISK:       true,
+	token.SLASH:          true,
+	token.LT:             true,
+	token.GT:             true,
+	token.EQ:             true,
+	token.NOT_EQ:         true,
+	token.ARROW:          true,
+	token.PIPE:           true,
+	token.BIT_AND:        true,
+	token.BIT_OR:         true,
+	token.BIT_XOR:        true,
+	token.BIT_NOT:        true,
+	token.SHIFT_LEFT:     true,
+	token.SHIFT_RIGHT:    true,
+	token.QUESTION:       true,
+	token.NULLISH:        true,
+	token.OPTIONAL_CHAIN: true,
+	token.COMMA:          true,
+	token.COLON:          true,
+	token.DOT:            true,
+	token.ELLIPSIS:       true,
+	token.FUNCTION:       true,
+	token.LET:            true,
+	token.IF:             true,
+	token.ELSE:           true,
+	token.FOR:            true,
+	token.IN:             true,
+	token.STRUCT:         true,
+}
+
+// report whether source needs more lines to be parsed, because it has
+// unclosed parentheses, brackets or braces, an unterminated string or ends
+// with an operator
+func Incomplete(source string) bool {
+	l := lexer.New(source)
+
+	depth := 0
+	last := token.Token{Type: token.EOF}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.COMMENT:
+			continue
+		case token.L_PAREN, token.L_BRACKET, token.L_BRACE:
+			depth++
+		case token.R_PAREN, token.R_BRACKET, token.R_BRACE:
+			depth--
+		}
+		last = tok
+	}
+
+	return depth > 0 || l.Unterminated() || continuedBy[last.Type]
+}
+
+// Input collects lines of the REPL until they form complete input
+type Input struct {
+	lines []string
+}
+
+// add line and return the collected input and true if it is complete, in
+// which case the collected lines are cleared
+func (in *Input) Add(line string) (string, bool) {
+	in.lines = append(in.lines, line)
+	source := strings.Join(in.lines, "\n")
+	if Incomplete(source) {
+		return "", false
+	}
+	in.lines = nil
+	return source, true
+}
+
+// report whether lines were added since the last complete input
+func (in *Input) Pending() bool {
+	return len(in.lines) > 0
+}
+
+// return and clear the lines collected so far, complete or not
+func (in *Input) Flush() string {
+	source := strings.Join(in.lines, "\n")
+	in.lines = nil
+	return source
+}
+
+// prompt for the next line
+func (in *Input) Prompt() string {
+	if in.Pending() {
+		return CONTINUATION_PROMPT
+	}
+	return PROMPT
+}
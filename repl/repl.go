@@ -2,9 +2,9 @@ package repl
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"io"
+	"monkey/diagnostic"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
@@ -13,18 +13,15 @@ import (
 
 const PROMPT = ">> "
 
-const MONKEY_FACE = `
- .--.  .-"      "-.  .--.
-/ .. \/  .-. .-.   \/ .. \
-| | '|  /   Y   \  |' |  |
-| \  \  \ 0 | 0 /  /  /  |
-\ '- ,\.-"""""""-./, -' /
- ''-' /_   ^ ^   _\ '-''
-     |  \._   _./  |
-     \   \ '~' /   /
-      '._ '-=-' _.'
-         '-----'
-`
+// prompt for lines continuing incomplete input
+const CONTINUATION_PROMPT = ".. "
+
+// renders parser and runtime errors, plain unless the frontend enables color
+var Renderer = diagnostic.Renderer{}
+
+// raise an error for out of range index instead of yielding null in sessions
+// started afterwards
+var StrictIndexing = false
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
@@ -34,13 +31,24 @@ func Start(in io.Reader, out io.Writer) {
 
 	go StartChannel(inChan, outChan)
 
+	input := &Input{}
 	for {
-		fmt.Fprint(out, PROMPT)
+		fmt.Fprint(out, input.Prompt())
 		scanned := scanner.Scan()
 		if !scanned {
+			// evaluate what is left to report why it is incomplete
+			if input.Pending() {
+				fmt.Fprintln(out)
+				inChan <- input.Flush()
+				io.WriteString(out, <-outChan)
+			}
 			return
 		}
-		inChan <- scanner.Text()
+		source, complete := input.Add(scanner.Text())
+		if !complete {
+			continue
+		}
+		inChan <- source
 		output := <-outChan
 		io.WriteString(out, output)
 	}
@@ -48,6 +56,7 @@ func Start(in io.Reader, out io.Writer) {
 
 func StartChannel(in chan string, out chan string) {
 	env := object.NewEnvironment()
+	env.Runtime().StrictIndexing = StrictIndexing
 
 	for {
 		line := <-in
@@ -56,11 +65,14 @@ func StartChannel(in chan string, out chan string) {
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			out <- printParseErrors(p.Errors())
+			out <- Renderer.RenderAll(line, p.Diagnostics())
 			continue
 		}
+		env.SetSource(line)
 		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		if err, ok := evaluated.(*object.Error); ok {
+			out <- renderError(err)
+		} else if evaluated != nil {
 			out <- evaluated.Inspect() + "\n"
 		} else {
 			out <- ""
@@ -68,13 +80,11 @@ func StartChannel(in chan string, out chan string) {
 	}
 }
 
-func printParseErrors(errors []string) string {
-	var out bytes.Buffer
-	out.WriteString(MONKEY_FACE)
-	out.WriteString("Woops! We ran into some monkey business here!\n")
-	out.WriteString("parser errors:\n")
-	for _, msg := range errors {
-		out.WriteString("\t" + msg + "\n")
+// render err against the input it was raised in, which may be an earlier
+// one defining the function that raised it, or without source if unknown
+func renderError(err *object.Error) string {
+	if err.Source == "" {
+		return Renderer.RenderError("", &object.Error{Message: err.Message})
 	}
-	return out.String()
+	return Renderer.RenderError(err.Source, err)
 }
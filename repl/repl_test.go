@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1;", false},
+		{"", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x + 1\n}", false},
+		{"[1, 2,", true},
+		{"puts((1 + 2)", true},
+		{"1 + 2)", false},
+		{`"unterminated`, true},
+		{"\"line\nbreak\"", false},
+		{`"${f("x"`, true},
+		{`"{"`, false},
+		{"let x = 1 +", true},
+		{"let x = 1 + // more below", true},
+		{"let x = 1 // }", false},
+		{"xs |>", true},
+		{"a ?", true},
+		{"h.", true},
+		{"let", true},
+		{"if (x) { 1 } else", true},
+		{"return", false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, Incomplete(tt.input), tt.input)
+	}
+}
+
+func TestInput(t *testing.T) {
+	input := &Input{}
+	require.Equal(t, PROMPT, input.Prompt())
+
+	source, complete := input.Add("let f = fn(x) {")
+	require.False(t, complete)
+	require.Empty(t, source)
+	require.True(t, input.Pending())
+	require.Equal(t, CONTINUATION_PROMPT, input.Prompt())
+
+	source, complete = input.Add("x * 2 }")
+	require.True(t, complete)
+	require.Equal(t, "let f = fn(x) {\nx * 2 }", source)
+	require.False(t, input.Pending())
+
+	input.Add("(1")
+	require.Equal(t, "(1", input.Flush())
+	require.False(t, input.Pending())
+}
+
+func TestStart(t *testing.T) {
+	in := strings.NewReader("let f = fn(x) {\n  x +\n    1\n};\nf(1)\nlet s = \"a\n\nb\"; len(s)\nf(\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := ">> .. .. .. >> 2\n" +
+		">> .. .. 4\n" +
+		">> .. \n" +
+		"error: no prefix parse function for EOF found\n" +
+		" --> 1:3\n" +
+		"  |\n" +
+		"1 | f(\n" +
+		"  |   ^\n" +
+		"  = help: the input ends before the expression is complete\n"
+	require.Equal(t, expected, out.String())
+}
+
+func TestErrorFromEarlierInput(t *testing.T) {
+	in := strings.NewReader("let f = fn(x) {\n  x + true\n};\nf(1)\nlet g = fn() { 1 / 0 }; 2\ng()\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := ">> .. .. >> error: type mismatch: INTEGER + BOOLEAN\n" +
+		" --> 2:5\n" +
+		"  |\n" +
+		"2 |   x + true\n" +
+		"  |     ^\n" +
+		">> 2\n" +
+		">> error: division by zero\n" +
+		" --> 1:18\n" +
+		"  |\n" +
+		"1 | let g = fn() { 1 / 0 }; 2\n" +
+		"  |                  ^\n" +
+		">> "
+	require.Equal(t, expected, out.String())
+}
+
+func TestErrorWithoutInput(t *testing.T) {
+	require.Equal(t, "error: oops\n", renderError(&object.Error{Message: "oops", Line: 3, Column: 4}))
+}
@@ -3,8 +3,10 @@ package token
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Line    int       `json:"line"`   // 1-based line of the first character
+	Column  int       `json:"column"` // 1-based column of the first character, counted in runes
 }
 
 const (
@@ -16,6 +18,9 @@ const (
 	INT        = "INT"
 	STRING     = "STRING"
 
+	// "// text" up to the end of line, skipped by the parser
+	COMMENT = "COMMENT"
+
 	// Operator
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -30,6 +35,20 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	ARROW = "=>"
+	PIPE  = "|>"
+
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	QUESTION       = "?"
+	NULLISH        = "??"
+	OPTIONAL_CHAIN = "?."
+
 	// Separator
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -41,7 +60,9 @@ const (
 	L_BRACKET = "["
 	R_BRACKET = "]"
 
-	COLON = ":"
+	COLON    = ":"
+	DOT      = "."
+	ELLIPSIS = "..."
 
 	// Reserved
 	FUNCTION = "FUNCTION"
@@ -51,16 +72,28 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	YIELD    = "YIELD"
+	STRUCT   = "STRUCT"
 )
 
 var reservedKeywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"yield":    YIELD,
+	"struct":   STRUCT,
 }
 
 func LookupIdentifier(ident string) TokenType {
@@ -69,3 +102,13 @@ func LookupIdentifier(ident string) TokenType {
 	}
 	return IDENTIFIER
 }
+
+// spelling of t in source, like "fn" for FUNCTION and ")" for R_PAREN
+func Spelling(t TokenType) string {
+	for keyword, tok := range reservedKeywords {
+		if tok == t {
+			return keyword
+		}
+	}
+	return string(t)
+}
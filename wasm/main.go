@@ -16,21 +16,37 @@ func main() {
 	fmt.Println("Initializing wasm")
 	go repl.StartChannel(in, out)
 
+	input := &repl.Input{}
+
 	js.Global().Set("writeCommand", js.FuncOf(func(this js.Value, s []js.Value) interface{} {
 		if len(s) == 0 {
 			return js.ValueOf("")
 		}
 
-		command := strings.Trim(s[0].String(), " ")
-		if command == "" {
+		command := s[0].String()
+		// blank lines matter inside of multi-line strings
+		if !input.Pending() {
+			command = strings.Trim(command, " ")
+			if command == "" {
+				return js.ValueOf("")
+			}
+		}
+
+		source, complete := input.Add(command)
+		if !complete {
 			return js.ValueOf("")
 		}
 
-		in <- command
+		in <- source
 		output := <-out
 
 		return js.ValueOf(output)
 	}))
 
+	// true while writeCommand waits for more lines of incomplete input
+	js.Global().Set("commandPending", js.FuncOf(func(this js.Value, s []js.Value) interface{} {
+		return js.ValueOf(input.Pending())
+	}))
+
 	<-c
 }